@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// AgendaEntry is an upcoming run of a task.
+type AgendaEntry struct {
+	// Name is the name of the job, or empty for unnamed tasks.
+	Name string
+	// Time is the occurrence the task is due at.
+	Time time.Time
+	// Handle is the task.
+	Handle *Handle
+}
+
+// Agenda returns the next k occurrences strictly after from across every running task,
+// sorted by time. Every task contributes at most k occurrences, so frequent tasks do
+// not make the computation unbounded. Disabled tasks are left out, and the occurrences
+// come from the schedules, without jitter or fixed delays.
+func (s *Scheduler) Agenda(from time.Time, k int) []AgendaEntry {
+	if k <= 0 {
+		return nil
+	}
+
+	var entries []AgendaEntry
+	for _, h := range s.tasks() {
+		if !h.Enabled() {
+			continue
+		}
+
+		h.mu.Lock()
+		ce := h.schedule
+		h.mu.Unlock()
+
+		n := 0
+		for next, ok := ce.Next(from); ok && n < k; next, ok = ce.Next(next) {
+			entries = append(entries, AgendaEntry{Name: h.name, Time: next, Handle: h})
+			n++
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Time.Before(entries[j].Time)
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries[:min(k, len(entries))]
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the agenda interleaves the occurrences of jobs with different cadences
+func TestAgenda(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	s := New(start, WithClock(NewFakeClock(start)))
+	defer s.Stop()
+
+	handler := func(Event) error { return nil }
+	if _, err := s.AddJob("fast", "@every 20m", handler); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.AddJob("slow", "@hourly", handler); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.AddJob("paused", "@every 1s", handler, WithDisabled()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"fast", 20 * time.Minute},
+		{"fast", 40 * time.Minute},
+		{"fast", 60 * time.Minute},
+		{"slow", 60 * time.Minute},
+		{"fast", 80 * time.Minute},
+		{"fast", 100 * time.Minute},
+	}
+
+	agenda := s.Agenda(start, len(want))
+	if len(agenda) != len(want) {
+		t.Fatalf("Expected %d entries, got %v", len(want), agenda)
+	}
+	for i, w := range want {
+		if agenda[i].Name != w.name || !agenda[i].Time.Equal(start.Add(w.offset)) {
+			t.Fatalf("Expected entry %d to be %s at %v, got %s at %v", i, w.name, start.Add(w.offset), agenda[i].Name, agenda[i].Time)
+		}
+	}
+
+	if agenda := s.Agenda(start, 0); len(agenda) != 0 {
+		t.Fatalf("Expected an empty agenda, got %v", agenda)
+	}
+}
+
+// Test the agenda can be read while Start anchors the held tasks
+func TestAgendaDuringStart(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock), WithStartBarrier())
+	defer s.Stop()
+
+	handler := func(Event) error { return nil }
+	if _, err := s.AddJob("plain", "@every 1m", handler); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.AddJob("delayed", "@every 1m", handler, WithInitialDelay(30*time.Second)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.Advance(10 * time.Second)
+	started := clock.Now()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			s.Agenda(started, 2)
+		}
+	}()
+	s.Start()
+	<-done
+	time.Sleep(20 * time.Millisecond)
+
+	// Both tasks count from the start once it is done.
+	want := []time.Time{started.Add(30 * time.Second), started.Add(time.Minute)}
+	agenda := s.Agenda(started, len(want))
+	if len(agenda) != len(want) {
+		t.Fatalf("Expected %d entries, got %v", len(want), agenda)
+	}
+	for i := range want {
+		if !agenda[i].Time.Equal(want[i]) {
+			t.Fatalf("Expected entry %d at %v, got %v", i, want[i], agenda[i].Time)
+		}
+	}
+}
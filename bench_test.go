@@ -0,0 +1,78 @@
+package scheduler
+
+// Ticks of @every tasks allocate nothing: the schedule is parsed once and the Event
+// is built on the stack. The remaining cost of a tick is dominated by reading the
+// clock, so the end of a synchronous run is reused to compute the next occurrence
+// instead of reading the clock again. Measure it with:
+//
+//	go test -run '^$' -bench 'Every|Fire' -benchmem
+//
+// With 1000 jobs, a few allocations may be reported. The runtime makes them for
+// goroutines blocking in select, not the ticks themselves.
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchmarkEvery runs jobs @every 1s tasks on a fake clock and measures one tick of
+// all of them per iteration.
+func benchmarkEvery(b *testing.B, jobs int) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		h, err := s.ScheduleHandle("@every 1s", func(Event) error {
+			wg.Done()
+			return nil
+		})
+		if err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+		defer h.Cancel()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// A tick is complete once every task has re-armed its timer.
+		clock.BlockUntilWaiters(jobs)
+
+		wg.Add(jobs)
+		clock.Advance(time.Second)
+		wg.Wait()
+	}
+}
+
+func BenchmarkEvery(b *testing.B) {
+	for _, jobs := range []int{1, 100, 1000} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			benchmarkEvery(b, jobs)
+		})
+	}
+}
+
+// BenchmarkFire measures the handling of a single tick, without the goroutine wakeups.
+func BenchmarkFire(b *testing.B) {
+	s := New(time.Now())
+
+	ce, err := parse("@every 1s")
+	if err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	h, err := s.newHandle(ce, func(Event) error { return nil })
+	if err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	h.wrapped = s.wrap(h.handler)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.fire(h.next)
+	}
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+// OverflowPolicy decides what happens to an event sent by ScheduleChan when the
+// channel's buffer is full.
+type OverflowPolicy int
+
+const (
+	// Block waits for the consumer to make room, delaying the task's following runs.
+	Block OverflowPolicy = iota
+	// DropNewest drops the event being sent.
+	DropNewest
+	// DropOldest drops the oldest buffered event to make room for the new one.
+	DropOldest
+)
+
+// String returns the name of the policy.
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropNewest:
+		return "drop newest"
+	case DropOldest:
+		return "drop oldest"
+	}
+	return "unknown"
+}
+
+// ScheduleChan sets up a scheduled task sending its events on a channel with the given
+// buffer size instead of calling a handler. When the buffer is full, the policy decides
+// which event is lost, if any; dropped events are counted in the handle's Stats. The
+// channel is closed once the task has stopped.
+func (s *Scheduler) ScheduleChan(expr string, buffer int, policy OverflowPolicy, opts ...JobOption) (<-chan Event, *Handle, error) {
+	ce, err := parse(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events := make(chan Event, buffer)
+
+	h, err := s.newHandle(ce, nil, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.async = false // Only one run may send at a time, and none after the channel is closed.
+	h.handler = func(event Event) error {
+		h.send(events, event, policy)
+		return nil
+	}
+	h.start()
+
+	go func() {
+		<-h.done
+		h.awaitExit()
+		close(events)
+	}()
+
+	return events, h, nil
+}
+
+// send delivers event on events, applying the overflow policy when the buffer is full.
+func (h *Handle) send(events chan Event, event Event, policy OverflowPolicy) {
+	select {
+	case events <- event:
+		return
+	default:
+	}
+
+	switch policy {
+	case Block:
+		select {
+		case events <- event:
+		case <-h.done:
+		}
+		return
+	case DropOldest:
+		// The consumer may have emptied the buffer meanwhile.
+		select {
+		case <-events:
+			h.recordDrop()
+		default:
+		}
+
+		select {
+		case events <- event:
+			return
+		default:
+		}
+	}
+
+	h.recordDrop()
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// waitRuns waits until the task has completed n runs.
+func waitRuns(t *testing.T, h *Handle, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for h.Stats().Runs < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d runs, got %d", n, h.Stats().Runs)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Test each overflow policy under a consumer that falls behind
+func TestScheduleChanPolicies(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		policy  OverflowPolicy
+		want    []time.Duration // Occurrences received, as offsets from start.
+		dropped int
+	}{
+		{DropNewest, []time.Duration{1, 2}, 3},
+		{DropOldest, []time.Duration{4, 5}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			clock := NewFakeClock(start)
+			s := New(start, WithClock(clock))
+
+			events, h, err := s.ScheduleChan("@every 1s", 2, tt.policy)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// Five occurrences fire before the consumer reads anything.
+			for i := 1; i <= 5; i++ {
+				clock.Advance(time.Second)
+				waitRuns(t, h, i)
+			}
+			h.Cancel()
+
+			var got []time.Time
+			for event := range events {
+				got = append(got, event.Scheduled)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d events, got %v", len(tt.want), got)
+			}
+			for i, offset := range tt.want {
+				if want := start.Add(offset * time.Second); !got[i].Equal(want) {
+					t.Fatalf("Expected event %d at %v, got %v", i, want, got[i])
+				}
+			}
+
+			if dropped := h.Stats().Dropped; dropped != tt.dropped {
+				t.Fatalf("Expected %d dropped events, got %d", tt.dropped, dropped)
+			}
+		})
+	}
+}
+
+// Test the Block policy holds back the task until the consumer catches up
+func TestScheduleChanBlock(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events, h, err := s.ScheduleChan("@every 1s", 1, Block)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The first event fills the buffer and the second blocks the task.
+	clock.Advance(time.Second)
+	waitRuns(t, h, 1)
+	clock.Advance(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if runs := h.Stats().Runs; runs != 1 {
+		t.Fatalf("Expected the blocked run not to complete, got %d runs", runs)
+	}
+
+	// The slow consumer receives every event, in order.
+	for i := 1; i <= 2; i++ {
+		event := <-events
+		if want := start.Add(time.Duration(i) * time.Second); !event.Scheduled.Equal(want) {
+			t.Fatalf("Expected event at %v, got %v", want, event.Scheduled)
+		}
+	}
+	waitRuns(t, h, 2)
+
+	h.Cancel()
+	if _, ok := <-events; ok {
+		t.Fatal("Expected the channel to be closed")
+	}
+	if dropped := h.Stats().Dropped; dropped != 0 {
+		t.Fatalf("Expected no dropped events, got %d", dropped)
+	}
+}
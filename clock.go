@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used by a Scheduler. It can be replaced to
+// control time in tests.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event timer created by a Clock, mirroring time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// realTimer adapts time.Timer to the Timer interface.
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// FakeClock is a Clock whose time only moves when Advance is called.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+
+	// armed is signalled whenever a timer is armed.
+	armed *sync.Cond
+}
+
+// NewFakeClock creates a new FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.armed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates a timer firing once the fake time has advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	t.arm(d)
+	return t
+}
+
+// Advance moves the fake time forward by d, firing every timer that expires.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		t.fire()
+	}
+}
+
+// BlockUntilWaiters blocks until at least n timers of the clock are waiting to fire.
+// Tasks wait on a timer for their next occurrence, so calling it before Advance makes
+// sure every task is ready to observe the new time. It never returns if fewer than n
+// timers are ever armed at once.
+func (c *FakeClock) BlockUntilWaiters(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.waiters() < n {
+		c.armed.Wait()
+	}
+}
+
+// waiters returns the number of armed timers. The clock's lock must be held.
+func (c *FakeClock) waiters() (n int) {
+	for _, t := range c.timers {
+		if t.active {
+			n++
+		}
+	}
+	return n
+}
+
+// fakeTimer is a Timer driven by a FakeClock.
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+// Stop prevents the timer from firing and reports whether it was active.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.active
+	t.active = false
+	return active
+}
+
+// Reset changes the timer to expire after d and reports whether it was active.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.active
+	t.arm(d)
+	return active
+}
+
+// arm sets the deadline of the timer. The clock's lock must be held.
+func (t *fakeTimer) arm(d time.Duration) {
+	t.deadline = t.clock.now.Add(d)
+	t.active = true
+	t.fire()
+	if t.active {
+		t.clock.armed.Broadcast()
+	}
+}
+
+// fire delivers the current time if the timer has expired. The clock's lock must be held.
+func (t *fakeTimer) fire() {
+	if !t.active || t.clock.now.Before(t.deadline) {
+		return
+	}
+
+	t.active = false
+	select {
+	case t.c <- t.clock.now:
+	default:
+	}
+}
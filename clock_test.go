@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitArmed waits until a timer of the clock is armed to expire at the given time.
+func waitArmed(t *testing.T, clock *FakeClock, at time.Time) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		clock.mu.Lock()
+		for _, timer := range clock.timers {
+			if timer.active && timer.deadline.Equal(at) {
+				clock.mu.Unlock()
+				return
+			}
+		}
+		clock.mu.Unlock()
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a timer armed for %v", at)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Test fake timers fire only once their deadline is reached
+func TestFakeClockTimer(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC))
+	timer := clock.NewTimer(time.Second)
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("Expected timer not to fire before its deadline")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case now := <-timer.C():
+		if !now.Equal(clock.Now()) {
+			t.Fatalf("Expected timer to deliver %v, got %v", clock.Now(), now)
+		}
+	default:
+		t.Fatal("Expected timer to fire at its deadline")
+	}
+
+	if timer.Stop() {
+		t.Fatal("Expected fired timer to be inactive")
+	}
+}
+
+// Test the first occurrence is computed from the scheduler's clock
+func TestScheduleFirstOccurrenceUsesClock(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start.Add(25 * time.Second))
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 1)
+	cancel, err := s.Schedule("@every 10s", func(event Event) error {
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	// The first occurrence after 10:00:25 is 10:00:30.
+	clock.Advance(4 * time.Second)
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no event before the first occurrence, got %v", event.Time)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case event := <-events:
+		if want := start.Add(30 * time.Second); !event.Time.Equal(want) {
+			t.Fatalf("Expected first event at %v, got %v", want, event.Time)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected first event to fire")
+	}
+}
+
+// Test blocking until tasks wait on the clock makes every advance fire each of them once
+func TestFakeClockBlockUntilWaiters(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	const jobs, ticks = 3, 5
+	var fires atomic.Int32
+	for i := 0; i < jobs; i++ {
+		h, err := s.ScheduleHandle("@every 1s", func(Event) error {
+			fires.Add(1)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer h.Cancel()
+	}
+
+	for i := 1; i <= ticks; i++ {
+		clock.BlockUntilWaiters(jobs)
+		clock.Advance(time.Second)
+	}
+	clock.BlockUntilWaiters(jobs)
+
+	if got := fires.Load(); got != jobs*ticks {
+		t.Fatalf("Expected %d fires, got %d", jobs*ticks, got)
+	}
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Config declares the named jobs of a Scheduler.
+type Config struct {
+	Jobs []JobConfig `json:"jobs" yaml:"jobs"`
+}
+
+// JobConfig declares a single named job.
+type JobConfig struct {
+	Name    string `json:"name" yaml:"name"`
+	Expr    string `json:"expr" yaml:"expr"`
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+}
+
+// ScheduleFromConfig adds a named job for every job of the config, running the handler
+// registered under the job's name. The whole config is validated first, so if any job
+// has an invalid expression, no handler, or a duplicate name, nothing is scheduled.
+func (s *Scheduler) ScheduleFromConfig(cfg Config, handlers map[string]Handler) error {
+	seen := make(map[string]bool, len(cfg.Jobs))
+	for _, job := range cfg.Jobs {
+		if _, ok := handlers[job.Name]; !ok {
+			return fmt.Errorf("job %q: no handler registered", job.Name)
+		}
+		if _, err := parse(job.Expr); err != nil {
+			return fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		if _, ok := s.Job(job.Name); ok || seen[job.Name] {
+			return fmt.Errorf("job %q: %w", job.Name, ErrJobExists)
+		}
+		seen[job.Name] = true
+	}
+
+	for _, job := range cfg.Jobs {
+		var opts []JobOption
+		if !job.Enabled {
+			opts = append(opts, WithDisabled())
+		}
+
+		if _, err := s.AddJob(job.Name, job.Expr, handlers[job.Name], opts...); err != nil {
+			return fmt.Errorf("job %q: %w", job.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Config returns the config of the scheduler's named jobs, sorted by name.
+func (s *Scheduler) Config() Config {
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+
+	var cfg Config
+	for name, h := range s.registry.jobs {
+		cfg.Jobs = append(cfg.Jobs, JobConfig{Name: name, Expr: h.Expr(), Enabled: h.Enabled()})
+	}
+	sort.Slice(cfg.Jobs, func(i, j int) bool { return cfg.Jobs[i].Name < cfg.Jobs[j].Name })
+
+	return cfg
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test round-tripping a config through JSON and the scheduler
+func TestScheduleFromConfigRoundTrip(t *testing.T) {
+	data := `{"jobs": [
+		{"name": "billing", "expr": "@daily", "enabled": true},
+		{"name": "cache", "expr": "@every 5m", "enabled": false}
+	]}`
+
+	var cfg Config
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	s := New(time.Now())
+	defer s.Stop()
+
+	handler := func(event Event) error { return nil }
+	err := s.ScheduleFromConfig(cfg, map[string]Handler{"billing": handler, "cache": handler})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if h, _ := s.Job("cache"); h.Enabled() {
+		t.Fatal("Expected disabled job to be disabled")
+	}
+
+	if got := s.Config(); !reflect.DeepEqual(got, cfg) {
+		t.Fatalf("Expected config %+v, got %+v", cfg, got)
+	}
+}
+
+// Test unknown handler names are rejected before scheduling anything
+func TestScheduleFromConfigUnknownHandler(t *testing.T) {
+	cfg := Config{Jobs: []JobConfig{
+		{Name: "billing", Expr: "@daily", Enabled: true},
+		{Name: "reports", Expr: "@hourly", Enabled: true},
+	}}
+
+	s := New(time.Now())
+	defer s.Stop()
+
+	err := s.ScheduleFromConfig(cfg, map[string]Handler{
+		"billing": func(event Event) error { return nil },
+	})
+	if err == nil || !strings.Contains(err.Error(), `"reports"`) {
+		t.Fatalf("Expected error naming the unknown handler, got %v", err)
+	}
+
+	if _, ok := s.Job("billing"); ok {
+		t.Fatal("Expected no job to be scheduled")
+	}
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test the per-run context is done once the handler returns
+func TestScheduleContextRunDone(t *testing.T) {
+	s := New(time.Now())
+
+	contexts := make(chan context.Context, 10)
+	cancel, err := s.ScheduleContext(context.Background(), "@every 10ms", func(ctx context.Context, event Event) error {
+		if ctx.Err() != nil {
+			t.Error("Expected run context to be live during the run")
+		}
+		contexts <- ctx
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case ctx := <-contexts:
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+				t.Fatal("Expected run context to be cancelled after the run")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected handler to run")
+		}
+	}
+}
+
+// Test cancelling the parent context cancels the in-flight run and stops the task
+func TestScheduleContextParentCancel(t *testing.T) {
+	s := New(time.Now())
+	parent, cancelParent := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	started := make(chan struct{})
+	var runErr error
+	_, err := s.ScheduleContext(parent, "@every 10ms", func(ctx context.Context, event Event) error {
+		defer wg.Done()
+		close(started)
+		<-ctx.Done()
+		runErr = ctx.Err()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	<-started
+	cancelParent()
+	wg.Wait()
+
+	if !errors.Is(runErr, context.Canceled) {
+		t.Fatalf("Expected run to be cancelled, got %v", runErr)
+	}
+}
+
+// Test the run timeout bounds the per-run context
+func TestScheduleContextRunTimeout(t *testing.T) {
+	s := New(time.Now())
+
+	errs := make(chan error, 1)
+	cancel, err := s.ScheduleContext(context.Background(), "@every 10ms", func(ctx context.Context, event Event) error {
+		<-ctx.Done()
+		select {
+		case errs <- ctx.Err():
+		default:
+		}
+		return nil
+	}, WithRunTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected run to time out")
+	}
+}
@@ -0,0 +1,341 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errNoDate is the error of cron expressions whose day fields match no date.
+var errNoDate = errors.New("matches no date")
+
+// cronBounds describes the range of values and the names accepted by a cron field.
+// Fields with nth accept the Quartz "d#n" form for the nth weekday d of the month.
+type cronBounds struct {
+	min, max uint
+	names    map[string]uint
+	nth      bool
+}
+
+var (
+	secondBounds = cronBounds{min: 0, max: 59}
+	minuteBounds = cronBounds{min: 0, max: 59}
+	hourBounds   = cronBounds{min: 0, max: 23}
+	domBounds    = cronBounds{min: 1, max: 31}
+	monthBounds  = cronBounds{min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 are Sunday.
+	dowBounds = cronBounds{min: 0, max: 7, nth: true, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// nthOffset is the first bit of the nth weekdays parsed along with a day of week field.
+// The nth weekday d of the month is bit nthOffset + 7*(n-1) + d.
+const nthOffset = 8
+
+// cronSpec is a parsed cron expression. Each field is a bit set of the values it matches.
+type cronSpec struct {
+	second, minute, hour, dom, month, dow uint64
+
+	// nth is a bit set of the nth weekdays of the month matched by the day of week
+	// field, such as the second Tuesday: bit 7*(n-1) + weekday.
+	nth uint64
+
+	// domAny and dowAny record an unrestricted day field. When both day fields are
+	// restricted, a day matching either of them matches, as in crontab.
+	domAny, dowAny bool
+}
+
+// parseCron parses a cron expression of five fields (minute, hour, day of month,
+// month, day of week) or six fields with a leading seconds field.
+// The day fields also accept the Quartz "?" character, equivalent to "*", and the day
+// of week field the Quartz "d#n" form for the nth weekday d of the month.
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, ParseError{Category: CategoryBadCron, Err: fmt.Errorf("expected 5 or 6 fields, got %d", len(fields))}
+	}
+
+	spec := &cronSpec{
+		domAny: fields[3] == "*" || fields[3] == "?",
+		dowAny: fields[5] == "*" || fields[5] == "?",
+	}
+
+	targets := []struct {
+		name     string
+		bits     *uint64
+		bounds   cronBounds
+		allowAny bool
+	}{
+		{"second", &spec.second, secondBounds, false},
+		{"minute", &spec.minute, minuteBounds, false},
+		{"hour", &spec.hour, hourBounds, false},
+		{"day of month", &spec.dom, domBounds, true},
+		{"month", &spec.month, monthBounds, false},
+		{"day of week", &spec.dow, dowBounds, true},
+	}
+	for i, target := range targets {
+		bits, err := parseCronField(fields[i], target.bounds, target.allowAny)
+		if err != nil {
+			return nil, ParseError{Field: target.name, Category: CategoryBadCron, Err: err}
+		}
+		*target.bits = bits
+	}
+
+	// Split the nth weekdays off the plain ones.
+	spec.nth = spec.dow >> nthOffset
+	spec.dow &= 1<<nthOffset - 1
+
+	// Fold Sunday as 7 into Sunday as 0.
+	if spec.dow&(1<<7) != 0 {
+		spec.dow = spec.dow&^(1<<7) | 1
+	}
+
+	// Reject days that never come, such as February 30, rather than a schedule that
+	// never fires. Five years from any date include a leap day.
+	if spec.next(time.Unix(0, 0).UTC()).IsZero() {
+		field := "day of month"
+		if spec.domAny {
+			field = "day of week"
+		}
+		return nil, ParseError{Field: field, Category: CategoryBadCron, Err: errNoDate}
+	}
+
+	return spec, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps.
+// allowAny reports whether the field accepts "?", which must be the whole field.
+func parseCronField(field string, bounds cronBounds, allowAny bool) (bits uint64, err error) {
+	if field == "?" && allowAny {
+		field = "*"
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		r, err := parseCronRange(part, bounds)
+		if err != nil {
+			return 0, err
+		}
+		bits |= r
+	}
+	return bits, nil
+}
+
+// parseCronRange parses a single "*", value, "a-b" range, or either followed by a "/n" step.
+// Steps count from the start of the range and do not carry over into the next period,
+// so "*/3" on hours matches 0, 3, ..., 21 and then 0 again the next day.
+func parseCronRange(part string, bounds cronBounds) (bits uint64, err error) {
+	if day, n, ok := strings.Cut(part, "#"); ok && bounds.nth {
+		return parseCronNth(day, n, bounds)
+	}
+
+	rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+	step := uint(1)
+	if hasStep {
+		n, err := strconv.ParseUint(stepPart, 10, 8)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("invalid step %q", part)
+		}
+		step = uint(n)
+	}
+
+	var lo, hi uint
+	switch {
+	case rangePart == "*":
+		lo, hi = bounds.min, bounds.max
+	case rangePart == "?":
+		return 0, fmt.Errorf("%q is only allowed alone in the day fields", part)
+	default:
+		loPart, hiPart, isRange := strings.Cut(rangePart, "-")
+		if lo, err = parseCronValue(loPart, bounds); err != nil {
+			return 0, err
+		}
+		switch {
+		case isRange:
+			if hi, err = parseCronValue(hiPart, bounds); err != nil {
+				return 0, err
+			}
+		case hasStep:
+			// A value with a step, such as "5/15", runs until the end of the range.
+			hi = bounds.max
+		default:
+			hi = lo
+		}
+	}
+
+	if lo > hi {
+		return 0, fmt.Errorf("invalid range %q", part)
+	}
+
+	for v := lo; v <= hi; v += step {
+		bits |= 1 << v
+	}
+	return bits, nil
+}
+
+// parseCronNth parses the nth weekday of the month, such as "2#2" or "TUE#2" for the
+// second Tuesday, into its bit at nthOffset.
+func parseCronNth(day, n string, bounds cronBounds) (uint64, error) {
+	weekday, err := parseCronValue(day, bounds)
+	if err != nil {
+		return 0, err
+	}
+
+	nth, err := strconv.ParseUint(n, 10, 8)
+	if err != nil || nth < 1 || nth > 5 {
+		return 0, fmt.Errorf("invalid weekday of the month %q", day+"#"+n)
+	}
+
+	// Sunday may be 7.
+	return 1 << (nthOffset + 7*(nth-1) + uint64(weekday%7)), nil
+}
+
+// parseCronValue parses a number or a name within the bounds of a field.
+func parseCronValue(s string, bounds cronBounds) (uint, error) {
+	if v, ok := bounds.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || uint(n) < bounds.min || uint(n) > bounds.max {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return uint(n), nil
+}
+
+// atCron returns the spec of a predefined alias with a time suffix: ":MM" fires @hourly
+// at MM minutes past every hour, ":HH:MM" fires daily aliases at HH:MM on their days.
+func atCron(alias string, days weekdaySet, at string) (*cronSpec, error) {
+	parts := strings.Split(strings.TrimPrefix(at, ":"), ":")
+	spec := &cronSpec{
+		second: 1,
+		dom:    ^uint64(0),
+		month:  ^uint64(0),
+		dow:    uint64(newWeekdaySet(time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday)),
+		domAny: true,
+		dowAny: true,
+	}
+
+	switch {
+	case alias == "@hourly" && len(parts) == 1:
+		spec.hour = 1<<24 - 1
+	case (alias == "@daily" || days != 0) && len(parts) == 2:
+		hour, err := parseCronValue(parts[0], hourBounds)
+		if err != nil {
+			return nil, ParseError{Field: "time", Category: CategoryBadTime, Err: err}
+		}
+		spec.hour = 1 << hour
+		if days != 0 {
+			spec.dow = uint64(days)
+		}
+		parts = parts[1:]
+	default:
+		return nil, ParseError{Field: "time", Category: CategoryBadTime, Err: fmt.Errorf("%s does not accept %q", alias, at)}
+	}
+
+	minute, err := parseCronValue(parts[0], minuteBounds)
+	if err != nil {
+		return nil, ParseError{Field: "time", Category: CategoryBadTime, Err: err}
+	}
+	spec.minute = 1 << minute
+
+	return spec, nil
+}
+
+// next returns the first instant strictly after t matching the spec, in t's location.
+// It returns the zero time if nothing matches within five years.
+func (c *cronSpec) next(t time.Time) time.Time {
+	loc := t.Location()
+
+	// Start from the following whole second. Truncating to the second boundary keeps
+	// occurrences on the top of the second even when t is an actual, late fire time.
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + 5
+
+	// Advance the coarsest mismatching field, resetting the finer ones. When a
+	// field wraps around, the coarser fields are checked again.
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for c.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !c.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	// Finer fields advance by elapsed time, so wall clock times repeated by a
+	// daylight saving transition cannot move backwards.
+	for c.hour&(1<<uint(t.Hour())) == 0 {
+		t = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for c.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Add(time.Minute - time.Duration(t.Second())*time.Second)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for c.second&(1<<uint(t.Second())) == 0 {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
+
+// dayMatches reports whether the day of t matches the day of month and day of week fields.
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0 || c.nth&(1<<uint(7*((t.Day()-1)/7)+int(t.Weekday()))) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// perDay estimates how many times a day the spec fires from the number of values
+// its fields match. Days of month are assumed equally likely up to the 31st.
+func (c *cronSpec) perDay() float64 {
+	dom := float64(bits.OnesCount64(c.dom)) / 31
+
+	// An nth weekday among the first four weeks of a month falls on one day in 31, and
+	// the fifth week has at most 3 days.
+	dow := float64(bits.OnesCount64(c.dow)) / 7
+	dow += float64(bits.OnesCount64(c.nth&(1<<28-1))) / 31
+	dow += float64(bits.OnesCount64(c.nth>>28)) * 3 / 7 / 31
+	days := dom * dow
+	if !c.domAny && !c.dowAny {
+		// Either field matching is enough.
+		days = dom + dow - dom*dow
+	}
+	days *= float64(bits.OnesCount64(c.month)) / 12
+
+	return float64(bits.OnesCount64(c.second)*bits.OnesCount64(c.minute)*bits.OnesCount64(c.hour)) * days
+}
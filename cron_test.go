@@ -0,0 +1,272 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Test the Quartz "?" character in the day of month field
+func TestCronQuestionMark(t *testing.T) {
+	ce, err := Parse("0 0 12 ? * MON")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ce.Kind != KindCron {
+		t.Fatalf("Expected kind %v, got %v", KindCron, ce.Kind)
+	}
+
+	from := time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC) // Wednesday
+	want := []time.Time{
+		time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 18, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 25, 12, 0, 0, 0, time.UTC),
+	}
+	times := ce.Between(from, from.AddDate(0, 0, 21))
+	if len(times) != len(want) {
+		t.Fatalf("Expected %d occurrences, got %v", len(want), times)
+	}
+	for i := range want {
+		if !times[i].Equal(want[i]) {
+			t.Fatalf("Expected occurrence %d at %v, got %v", i, want[i], times[i])
+		}
+	}
+}
+
+// Test "?" is equivalent to "*" in the day of week field
+func TestCronQuestionMarkDayOfWeek(t *testing.T) {
+	ce, err := Parse("0 30 8 15 * ?")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	next := ce.NextOccurrence(time.Date(2024, 3, 15, 8, 30, 0, 0, time.UTC))
+	if want := time.Date(2024, 4, 15, 8, 30, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+}
+
+// Test "?" is rejected outside the day fields and as part of a list
+func TestCronQuestionMarkInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"? 0 12 * * MON",
+		"0 ? 12 * * MON",
+		"0 0 ? * * MON",
+		"0 0 12 * ? MON",
+		"0 0 12 ?/2 * MON",
+		"0 0 12 ?,1 * MON",
+		"0 0 12 * * MON,?",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("Expected error for %q", expr)
+		}
+	}
+}
+
+// Test six field expressions with a leading seconds field
+func TestCronSixFields(t *testing.T) {
+	ce, err := Parse("0 0 12 * * MON")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ce.Kind != KindCron {
+		t.Fatalf("Expected kind %v, got %v", KindCron, ce.Kind)
+	}
+
+	from := time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC) // Wednesday
+	want := []time.Time{
+		time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 18, 12, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 25, 12, 0, 0, 0, time.UTC),
+	}
+	times := ce.Between(from, from.AddDate(0, 0, 21))
+	if len(times) != len(want) {
+		t.Fatalf("Expected %d occurrences, got %v", len(want), times)
+	}
+	for i := range want {
+		if !times[i].Equal(want[i]) {
+			t.Fatalf("Expected occurrence %d at %v, got %v", i, want[i], times[i])
+		}
+	}
+}
+
+// Test five field expressions fire at the top of the minute
+func TestCronFiveFields(t *testing.T) {
+	ce, err := Parse("15 9 * * 1-5")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Friday morning rolls over to Monday.
+	next := ce.NextOccurrence(time.Date(2024, 3, 8, 9, 15, 0, 0, time.UTC))
+	if want := time.Date(2024, 3, 11, 9, 15, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+
+	if !ce.Matches(next) || ce.Matches(next.Add(time.Second)) {
+		t.Fatal("Expected only the occurrence itself to match")
+	}
+}
+
+// Test stepped hours fire on every third hour and wrap around to midnight
+func TestCronStepHours(t *testing.T) {
+	ce, err := Parse("0 */3 * * *")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	from := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	times := ce.Between(from, from.Add(27*time.Hour))
+
+	var hours []int
+	for _, next := range times {
+		hours = append(hours, next.Hour())
+	}
+	want := []int{0, 3, 6, 9, 12, 15, 18, 21, 0}
+	if fmt.Sprint(hours) != fmt.Sprint(want) {
+		t.Fatalf("Expected hours %v, got %v", want, hours)
+	}
+	if last := times[len(times)-1]; !last.Equal(from.AddDate(0, 0, 1)) {
+		t.Fatalf("Expected wrap to the next midnight, got %v", last)
+	}
+}
+
+// Test stepped minutes with a start value and within a range
+func TestCronStepMinutes(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		want []int
+	}{
+		{"*/20 * * * *", []int{0, 20, 40}},
+		{"5/15 * * * *", []int{5, 20, 35, 50}},
+		{"10-30/10 * * * *", []int{10, 20, 30}},
+		{"0,45-59/7 * * * *", []int{0, 45, 52, 59}},
+	} {
+		ce, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		from := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+		var minutes []int
+		for _, next := range ce.Between(from, from.Add(time.Hour)) {
+			minutes = append(minutes, next.Minute())
+		}
+		if fmt.Sprint(minutes) != fmt.Sprint(tt.want) {
+			t.Fatalf("%s: expected minutes %v, got %v", tt.expr, tt.want, minutes)
+		}
+	}
+}
+
+// Test invalid steps are rejected
+func TestCronStepInvalid(t *testing.T) {
+	for _, expr := range []string{"*/0 * * * *", "*/x * * * *", "0 */-1 * * *", "0 24/2 * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("Expected error for %q", expr)
+		}
+	}
+}
+
+// Test a per-second cron task stays on the top of the second without drifting
+func TestCronEverySecondNoDrift(t *testing.T) {
+	// The scheduler starts and runs 300ms off the second boundary.
+	base := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(base.Add(300 * time.Millisecond))
+	s := New(clock.Now(), WithClock(clock))
+
+	events := make(chan Event)
+	h, err := s.ScheduleHandle("* * * * * *", func(event Event) error {
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	for i := 1; i <= 10000; i++ {
+		// Advance only once the task waits for its next occurrence, or it may skip it.
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Second)
+		select {
+		case event := <-events:
+			if want := base.Add(time.Duration(i) * time.Second); !event.Scheduled.Equal(want) {
+				t.Fatalf("Expected occurrence %d at %v, got %v", i, want, event.Scheduled)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected occurrence %d to fire", i)
+		}
+	}
+}
+
+// Test occurrences are computed from the truncated second boundary
+func TestCronTruncatesToSecond(t *testing.T) {
+	ce, err := Parse("* * * * * *")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	late := time.Date(2024, 3, 8, 10, 0, 5, 999999999, time.UTC)
+	if next, want := ce.NextOccurrence(late), time.Date(2024, 3, 8, 10, 0, 6, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+}
+
+// Test the nth weekday of the month, skipping months without it
+func TestCronNthWeekday(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want []time.Time
+	}{
+		// The second Tuesday.
+		{"0 0 9 ? * 2#2", []time.Time{
+			time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 13, 9, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC),
+		}},
+		// The fifth Friday, which most months lack.
+		{"0 0 9 ? * FRI#5", []time.Time{
+			time.Date(2024, 3, 29, 9, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 31, 9, 0, 0, 0, time.UTC),
+			time.Date(2024, 8, 30, 9, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	for _, tt := range tests {
+		ce, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		next := from
+		for i, want := range tt.want {
+			if next = ce.NextOccurrence(next); !next.Equal(want) {
+				t.Fatalf("%s: expected occurrence %d at %v, got %v", tt.expr, i, want, next)
+			}
+		}
+	}
+
+	for _, expr := range []string{"0 0 9 ? * 2#0", "0 0 9 ? * 2#6", "0 0 9 ? * 1-2#2", "0 0 9 1#2 * ?"} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("Expected error for %q", expr)
+		}
+	}
+}
+
+// Test a leap day is a valid date while February 30 is not
+func TestCronLeapDay(t *testing.T) {
+	ce, err := Parse("0 0 29 2 *")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	next := ce.NextOccurrence(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+
+	if _, err := Parse("0 0 30 2 *"); err == nil {
+		t.Fatal("Expected an error for February 30")
+	}
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// processStart anchors the default scheduler.
+	processStart = time.Now()
+
+	defaultScheduler     *Scheduler
+	defaultSchedulerOnce sync.Once
+
+	// defaultLocation is the location of schedulers created without WithLocation, if set.
+	defaultLocation atomic.Pointer[time.Location]
+)
+
+// SetDefaultLocation sets the time zone calendar schedules are computed in for every
+// scheduler created afterwards without WithLocation, including the default scheduler
+// if it was not used yet. A TZ= or CRON_TZ= prefix still overrides it for a single
+// expression. A nil location restores the default, the location of the start time.
+func SetDefaultLocation(loc *time.Location) {
+	defaultLocation.Store(loc)
+}
+
+// Default returns the package-level Scheduler used by Every, anchored at process start.
+// It is created on first use.
+func Default() *Scheduler {
+	defaultSchedulerOnce.Do(func() {
+		defaultScheduler = New(processStart)
+	})
+	return defaultScheduler
+}
+
+// Every schedules handler on the default scheduler every interval, where interval
+// is a duration such as "1m" or "10h20m". It is shorthand for
+// Default().Schedule("@every "+interval, handler).
+func Every(interval string, handler Handler, opts ...JobOption) (func(), error) {
+	return Default().Schedule("@every "+interval, handler, opts...)
+}
+
+// StopAll cancels every task of the default scheduler.
+func StopAll() {
+	Default().Stop()
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test the default scheduler is created once under concurrent use
+func TestDefaultConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	schedulers := make([]*Scheduler, 10)
+	for i := range schedulers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			schedulers[i] = Default()
+		}(i)
+	}
+	wg.Wait()
+
+	for _, s := range schedulers {
+		if s != schedulers[0] {
+			t.Fatal("Expected a single default scheduler")
+		}
+	}
+}
+
+// Test Every and StopAll
+func TestEveryStopAll(t *testing.T) {
+	var count atomic.Int32
+	_, err := Every("20ms", func(event Event) error {
+		count.Add(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := Every("soon", func(event Event) error { return nil }); err == nil {
+		t.Fatal("Expected error for invalid interval, got nil")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	StopAll()
+
+	stopped := count.Load()
+	if stopped == 0 {
+		t.Fatal("Expected handler to run")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if count.Load() != stopped {
+		t.Fatal("Expected no runs after StopAll")
+	}
+}
+
+// Test the default location applies to new schedulers unless overridden
+func TestSetDefaultLocation(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	SetDefaultLocation(jst)
+	defer SetDefaultLocation(nil)
+
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC) // 19:00 in JST
+	clock := NewFakeClock(start)
+	handler := func(Event) error { return nil }
+
+	tests := []struct {
+		expr string
+		opts []Option
+		want time.Time
+	}{
+		// Midnight in the default location.
+		{"@daily", nil, time.Date(2024, 3, 9, 0, 0, 0, 0, jst)},
+		// The scheduler's location overrides it.
+		{"@daily", []Option{WithLocation(time.UTC)}, time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)},
+		// So does the expression's.
+		{"TZ=UTC @daily", nil, time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s := New(start, append(tt.opts, WithClock(clock))...)
+		h, err := s.ScheduleHandle(tt.expr, handler)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if first := h.FirstRun(); !first.Equal(tt.want) {
+			t.Fatalf("%s: expected the first run at %v, got %v", tt.expr, tt.want, first)
+		}
+		h.Cancel()
+	}
+
+	// Restoring the default uses the location of the start time again.
+	SetDefaultLocation(nil)
+	s := New(start, WithClock(clock))
+	h, err := s.ScheduleHandle("@daily", handler)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+	if want := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC); !h.FirstRun().Equal(want) {
+		t.Fatalf("Expected the first run at %v, got %v", want, h.FirstRun())
+	}
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Describe returns a readable summary of when the task fires, combining its
+// expression with every option affecting the timing of its runs, such as
+// "@every 5m, aligned, jitter up to 10s, active 09:00-17:00, in UTC".
+func (h *Handle) Describe() string {
+	h.mu.Lock()
+	ce := h.schedule
+	h.mu.Unlock()
+
+	parts := []string{describeSchedule(ce)}
+	add := func(format string, args ...any) {
+		parts = append(parts, fmt.Sprintf(format, args...))
+	}
+
+	if h.initialDelay > 0 {
+		add("initial delay %v", h.initialDelay)
+	}
+	if h.skipFirst > 0 {
+		add("skip first %d", h.skipFirst)
+	}
+	if h.alignFirst {
+		add("aligned")
+	}
+	if h.fixedDelay > 0 {
+		add("fixed delay %v", h.fixedDelay)
+	}
+	if h.maxJitter > 0 {
+		add("jitter up to %v", h.maxJitter)
+	}
+	if h.randomWithin {
+		add("random within interval")
+	}
+	for _, f := range ce.filters {
+		switch f := f.(type) {
+		case windowFilter:
+			add("active %s-%s", formatClock(f.start), formatClock(f.end))
+		case weekdayFilter:
+			add("business days")
+		case dateFilter:
+			add("excluding %d dates", len(f))
+		}
+	}
+	if h.debounce > 0 {
+		add("debounce %v", h.debounce)
+	}
+	if h.throttle > 0 {
+		add("throttle %v", h.throttle)
+	}
+	if h.catchUp != 1 {
+		add("catch up %d missed runs", h.catchUp)
+	}
+	if h.maxRuns > 0 {
+		add("at most %d runs", h.maxRuns)
+	}
+	if h.runTimeout > 0 {
+		add("timeout %v", h.runTimeout)
+	}
+	if h.async {
+		add("async")
+	}
+	if h.manual {
+		add("manual advance")
+	}
+	if !h.Enabled() {
+		add("disabled")
+	}
+	if ce.Location != nil {
+		add("in %s", ce.Location)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// describeSchedule returns the expression of ce, or a description of schedules
+// created without one.
+func describeSchedule(ce *Schedule) string {
+	switch {
+	case ce.Expr != "":
+		return ce.Expr
+	case ce.Source != nil:
+		return "custom source"
+	case ce.Frequency > 0:
+		return "@every " + ce.Frequency.String()
+	}
+	return "unknown schedule"
+}
+
+// formatClock formats an offset from midnight as a wall clock time.
+func formatClock(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset/time.Hour), int(offset%time.Hour/time.Minute))
+}
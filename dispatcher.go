@@ -0,0 +1,193 @@
+package scheduler
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Dispatcher runs the tasks of any number of schedulers on a single timer goroutine
+// and a fixed pool of workers. It always uses the system clock.
+//
+// Runs due at the same instant are handed to the workers in the order their tasks were
+// created, across schedulers. With a single worker they therefore run in that order;
+// with more workers they start in that order but may overlap.
+type Dispatcher struct {
+	clock Clock
+
+	mu    sync.Mutex
+	queue dispatchQueue
+
+	wake chan struct{}
+	work chan func()
+	quit chan struct{}
+	once sync.Once
+}
+
+// NewDispatcher creates a Dispatcher running handlers on the given number of workers.
+func NewDispatcher(workers int) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	d := &Dispatcher{
+		clock: realClock{},
+		wake:  make(chan struct{}, 1),
+		work:  make(chan func()),
+		quit:  make(chan struct{}),
+	}
+
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+	go d.loop()
+
+	return d
+}
+
+// Stop stops the timer goroutine and the workers. Tasks using the dispatcher no
+// longer fire afterwards.
+func (d *Dispatcher) Stop() {
+	d.once.Do(func() {
+		close(d.quit)
+	})
+}
+
+// add queues h to fire at the given time.
+func (d *Dispatcher) add(h *Handle, at time.Time) {
+	d.mu.Lock()
+	heap.Push(&d.queue, dispatchEntry{h, at})
+	d.mu.Unlock()
+
+	// Wake the timer goroutine in case h is now the earliest task.
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// trigger runs an out of band trigger of h on a worker.
+func (d *Dispatcher) trigger(h *Handle) {
+	go d.submit(func() {
+		h.runMu.Lock()
+		defer h.runMu.Unlock()
+
+		select {
+		case <-h.done:
+		case <-h.trigger:
+			h.runTrigger()
+		}
+	})
+}
+
+// submit hands f to a worker, unless the dispatcher was stopped.
+func (d *Dispatcher) submit(f func()) {
+	select {
+	case d.work <- f:
+	case <-d.quit:
+	}
+}
+
+// worker executes submitted runs until the dispatcher is stopped.
+func (d *Dispatcher) worker() {
+	for {
+		select {
+		case f := <-d.work:
+			f()
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// loop waits for the earliest queued task and hands every due task to a worker.
+func (d *Dispatcher) loop() {
+	timer := d.clock.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		d.mu.Lock()
+		now := d.clock.Now()
+		var due []*Handle
+		for len(d.queue) > 0 && !d.queue[0].at.After(now) {
+			entry := heap.Pop(&d.queue).(dispatchEntry)
+			due = append(due, entry.handle)
+		}
+
+		wait := time.Hour
+		if len(d.queue) > 0 {
+			wait = d.queue[0].at.Sub(now)
+		}
+		d.mu.Unlock()
+
+		for _, h := range due {
+			d.submit(func() { d.fire(h) })
+		}
+
+		timer.Stop()
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C():
+		case <-d.wake:
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// fire runs the due occurrence of h and queues its next occurrence.
+func (d *Dispatcher) fire(h *Handle) {
+	h.runMu.Lock()
+	defer h.runMu.Unlock()
+
+	// Cancelled tasks are dropped from the queue.
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+
+	if !h.fire(d.clock.Now()) {
+		return
+	}
+
+	// Tasks with manual advance are held until Advance is called, unless it already was.
+	if h.manual {
+		h.parked = true
+		h.unpark()
+		return
+	}
+
+	d.add(h, h.due())
+}
+
+// dispatchEntry is a task queued to fire at a given time.
+type dispatchEntry struct {
+	handle *Handle
+	at     time.Time
+}
+
+// dispatchQueue is a min-heap of queued tasks ordered by fire time, and by creation
+// for tasks firing at the same time.
+type dispatchQueue []dispatchEntry
+
+func (q dispatchQueue) Len() int      { return len(q) }
+func (q dispatchQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q dispatchQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].handle.seq < q[j].handle.seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q *dispatchQueue) Push(x any) { *q = append(*q, x.(dispatchEntry)) }
+
+func (q *dispatchQueue) Pop() any {
+	old := *q
+	n := len(old)
+	entry := old[n-1]
+	*q = old[:n-1]
+	return entry
+}
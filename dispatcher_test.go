@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test several schedulers sharing a dispatcher
+func TestDispatcherSharedBySchedulers(t *testing.T) {
+	d := NewDispatcher(2)
+	defer d.Stop()
+
+	var a, b atomic.Int32
+	s1 := New(time.Now(), WithDispatcher(d))
+	s2 := New(time.Now(), WithDispatcher(d))
+
+	cancel1, err := s1.Schedule("@every 20ms", func(event Event) error { a.Add(1); return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cancel2, err := s2.Schedule("@every 30ms", func(event Event) error { b.Add(1); return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	cancel1()
+	cancel2()
+
+	if a.Load() == 0 || b.Load() == 0 {
+		t.Fatalf("Expected both schedulers to fire, got %d and %d", a.Load(), b.Load())
+	}
+
+	// Cancelled tasks no longer fire.
+	before := a.Load()
+	time.Sleep(50 * time.Millisecond)
+	if a.Load() != before {
+		t.Fatal("Expected cancelled task not to fire")
+	}
+}
+
+// Test a dispatched task stops on handler error and can be triggered
+func TestDispatcherHandlerErrorAndTrigger(t *testing.T) {
+	d := NewDispatcher(1)
+	defer d.Stop()
+
+	s := New(time.Now(), WithDispatcher(d))
+	runs := make(chan struct{}, 1)
+	h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+		runs <- struct{}{}
+		return fmt.Errorf("stop")
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h.Trigger()
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("Expected triggered run")
+	}
+
+	<-h.Done()
+	if h.Err() == nil {
+		t.Fatal("Expected handler error to stop the task")
+	}
+}
+
+// Test coincident dispatched runs happen in the order their tasks were created
+func TestDispatcherCoincidentOrder(t *testing.T) {
+	d := NewDispatcher(1)
+	defer d.Stop()
+
+	start := time.Now()
+	schedulers := []*Scheduler{New(start, WithDispatcher(d)), New(start, WithDispatcher(d))}
+
+	const jobs, ticks = 8, 2
+	order := make(chan int, jobs*ticks)
+	for i := 0; i < jobs; i++ {
+		h, err := schedulers[i%2].ScheduleHandle("@every 30ms", func(event Event) error {
+			order <- i
+			return nil
+		}, WithMaxRuns(ticks))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer h.Cancel()
+	}
+
+	for tick := 0; tick < ticks; tick++ {
+		for i := 0; i < jobs; i++ {
+			select {
+			case got := <-order:
+				if got != i {
+					t.Fatalf("Expected job %d to run next in tick %d, got job %d", i, tick, got)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Expected job %d to run", i)
+			}
+		}
+	}
+}
+
+// benchmarkGoroutines schedules jobs across several schedulers and reports the
+// number of goroutines they use on top of those running before the benchmark.
+func benchmarkGoroutines(b *testing.B, opts ...Option) {
+	const schedulers, jobs = 10, 100
+
+	base := runtime.NumGoroutine()
+	for i := 0; i < b.N; i++ {
+		var all []*Scheduler
+		for j := 0; j < schedulers; j++ {
+			s := New(time.Now(), opts...)
+			for k := 0; k < jobs; k++ {
+				s.Schedule("@every 1h", func(event Event) error { return nil })
+			}
+			all = append(all, s)
+		}
+
+		b.ReportMetric(float64(runtime.NumGoroutine()-base), "goroutines")
+
+		// Wait for the tasks to exit, so the next iteration starts from the baseline.
+		for _, s := range all {
+			s.Shutdown()
+		}
+	}
+}
+
+func BenchmarkGoroutinesPerTask(b *testing.B) {
+	benchmarkGoroutines(b)
+}
+
+func BenchmarkGoroutinesDispatcher(b *testing.B) {
+	d := NewDispatcher(4)
+	defer d.Stop()
+	benchmarkGoroutines(b, WithDispatcher(d))
+}
+
+// Test handlers can schedule and look up jobs on their own scheduler
+func TestScheduleFromHandler(t *testing.T) {
+	d := NewDispatcher(1)
+	defer d.Stop()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"goroutines", nil},
+		// A single worker is busy running the scanner while it schedules.
+		{"dispatcher", []Option{WithDispatcher(d)}},
+	}
+
+	for _, tt := range tests {
+		s := New(time.Now(), tt.opts...)
+		const scans = 5
+		followUps := make(chan string, scans)
+
+		var n atomic.Int32
+		scanner, err := s.AddJob("scanner", "@every 10ms", func(Event) error {
+			name := fmt.Sprintf("follow-up-%d", n.Add(1))
+			_, err := s.AddJob(name, "@every 1ms", func(event Event) error {
+				if h, ok := s.Job(name); !ok || h != event.Handle {
+					t.Errorf("%s: expected %s to be registered", tt.name, name)
+				}
+				followUps <- name
+				return ErrStop
+			})
+			return err
+		}, WithMaxRuns(scans))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for i := 0; i < scans; i++ {
+			select {
+			case <-followUps:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: expected %d follow-ups, got %d", tt.name, scans, i)
+			}
+		}
+		if reason, err := scanner.Wait(); reason != Exhausted {
+			t.Fatalf("%s: expected the scanner to be exhausted, got %v: %v", tt.name, reason, err)
+		}
+		s.Shutdown()
+	}
+}
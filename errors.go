@@ -0,0 +1,70 @@
+package scheduler
+
+import "fmt"
+
+// Category classifies why an expression could not be parsed.
+type Category int
+
+const (
+	// CategoryUnknownAlias means the expression matches no known alias or form.
+	CategoryUnknownAlias Category = iota + 1
+	// CategoryBadDuration means an @every interval is malformed or zero.
+	CategoryBadDuration
+	// CategoryBadCron means a cron expression has the wrong number of fields, a
+	// field outside its range, or day fields matching no date, such as February 30.
+	CategoryBadCron
+	// CategoryBadTime means a time of day suffix or an interval anchor is invalid.
+	CategoryBadTime
+	// CategoryBadZone means a TZ= or CRON_TZ= prefix names an unknown time zone.
+	CategoryBadZone
+)
+
+// String returns the name of the category.
+func (c Category) String() string {
+	switch c {
+	case CategoryUnknownAlias:
+		return "unknown alias"
+	case CategoryBadDuration:
+		return "bad duration"
+	case CategoryBadCron:
+		return "bad cron"
+	case CategoryBadTime:
+		return "bad time"
+	case CategoryBadZone:
+		return "bad zone"
+	}
+	return "unknown"
+}
+
+// ParseError is returned when an expression cannot be parsed.
+type ParseError struct {
+	// Expr is the offending expression.
+	Expr string
+	// Field names the part of the expression at fault, such as the cron field
+	// "hour", if known.
+	Field string
+	// Category classifies the failure.
+	Category Category
+	// Err is the underlying error.
+	Err error
+}
+
+// Error describes the expression and what is wrong with it.
+func (e ParseError) Error() string {
+	msg := "invalid expression"
+	if e.Expr != "" {
+		msg += fmt.Sprintf(" %q", e.Expr)
+	}
+	if e.Field != "" {
+		msg += ": " + e.Field
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e ParseError) Unwrap() error {
+	return e.Err
+}
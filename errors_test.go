@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test parse errors carry the expression, field and category
+func TestParseErrorCategories(t *testing.T) {
+	tests := []struct {
+		expr     string
+		category Category
+		field    string
+	}{
+		{"@never", CategoryUnknownAlias, ""},
+		{"@every 0s", CategoryBadDuration, ""},
+		{"@every 1m,0s", CategoryBadDuration, ""},
+		{"@every 1m,5m,10", CategoryBadDuration, ""},
+		{"@every 5m,abc", CategoryBadDuration, ""},
+		{"@every 5m,", CategoryBadDuration, ""},
+		{"0 0 * *", CategoryBadCron, ""},
+		{"0 25 * * *", CategoryBadCron, "hour"},
+		{"0 0 12 * ? MON", CategoryBadCron, "month"},
+		{"0 0 30 2 *", CategoryBadCron, "day of month"},
+		{"0 0 31 4,6,9,11 *", CategoryBadCron, "day of month"},
+		{"@hourly:75", CategoryBadTime, "time"},
+		{"@every 90m@03:00", CategoryBadTime, "anchor"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+
+		var pe ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("Expected a ParseError for %q, got %v", tt.expr, err)
+		}
+		if pe.Expr != tt.expr {
+			t.Fatalf("Expected expression %q, got %q", tt.expr, pe.Expr)
+		}
+		if pe.Category != tt.category {
+			t.Fatalf("Expected category %v for %q, got %v", tt.category, tt.expr, pe.Category)
+		}
+		if pe.Field != tt.field {
+			t.Fatalf("Expected field %q for %q, got %q", tt.field, tt.expr, pe.Field)
+		}
+	}
+}
+
+// Test scheduling returns parse errors usable with errors.As
+func TestScheduleParseError(t *testing.T) {
+	s := New(time.Now())
+
+	_, err := s.Schedule("@every 1x", func(event Event) error { return nil })
+	if !errors.As(err, &ParseError{}) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+}
@@ -0,0 +1,1040 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStop can be returned by a handler to end its task cleanly. The task stops
+// with reason HandlerStopped and no error.
+var ErrStop = errors.New("stop schedule")
+
+// handleSeq numbers tasks in the order they are created, across schedulers.
+var handleSeq atomic.Uint64
+
+// ErrShutdownTimeout is returned by ShutdownTimeout when a run did not finish in time.
+var ErrShutdownTimeout = errors.New("shutdown timed out")
+
+// StopReason describes why a task stopped.
+type StopReason int
+
+const (
+	// Running means the task has not stopped yet.
+	Running StopReason = iota
+	// Cancelled means the task was cancelled.
+	Cancelled
+	// HandlerFailed means the handler returned an error.
+	HandlerFailed
+	// HandlerStopped means the handler returned ErrStop.
+	HandlerStopped
+	// Exhausted means the schedule has no further occurrences, or the task
+	// reached its maximum number of runs.
+	Exhausted
+)
+
+// String returns the name of the stop reason.
+func (r StopReason) String() string {
+	switch r {
+	case Running:
+		return "running"
+	case Cancelled:
+		return "cancelled"
+	case HandlerFailed:
+		return "handler failed"
+	case HandlerStopped:
+		return "handler stopped"
+	case Exhausted:
+		return "exhausted"
+	}
+	return "unknown"
+}
+
+// Handle controls a scheduled task and reports why it stopped.
+type Handle struct {
+	scheduler *Scheduler
+	name      string
+	tags      []string
+	metadata  map[string]string
+	schedule  *Schedule
+	handler   Handler
+	clock     Clock
+	next      time.Time
+
+	// wrapped is the handler wrapped by the scheduler's middlewares, set before the first run.
+	wrapped Handler
+
+	// skipped is the number of occurrences that passed before next was computed.
+	skipped int
+
+	// firstRun is the first occurrence determined for the task, guarded by statsMu.
+	firstRun time.Time
+
+	// history is a ring buffer of the most recent events, of capacity historySize,
+	// with the oldest at historyPos once it is full. It is guarded by statsMu.
+	historySize int
+	history     []Event
+	historyPos  int
+
+	// dispatcher runs the task instead of a dedicated goroutine when set.
+	// runMu serializes the runs it executes on its workers.
+	dispatcher *Dispatcher
+	runMu      sync.Mutex
+
+	// seq orders tasks by creation, so coincident dispatched runs start in that order.
+	seq uint64
+
+	done    chan struct{}
+	once    sync.Once
+	trigger chan struct{}
+	advance chan struct{}
+	exited  chan struct{}
+
+	// parked is set, under runMu, while a dispatched task with manual advance
+	// waits for Advance.
+	parked bool
+
+	// ctx is cancelled when the task stops. Run contexts derive from it.
+	parent    context.Context
+	ctx       context.Context
+	cancelCtx context.CancelFunc
+
+	disabled  atomic.Bool
+	succeeded atomic.Bool
+	durations *Histogram
+
+	// pending is a schedule set by Reschedule, applied when the next occurrence is computed.
+	pending atomic.Pointer[Schedule]
+
+	statsMu sync.Mutex
+	stats   Stats
+	nextRun time.Time
+
+	fixedDelay   time.Duration
+	alignFirst   bool
+	initialDelay time.Duration
+	skipFirst    int
+	runTimeout   time.Duration
+	maxJitter    time.Duration
+	jitter       time.Duration
+	lagThreshold time.Duration
+	onLag        func(event Event, lag time.Duration)
+	batchWait    time.Duration
+	batchSize    int
+	catchUp      int
+	async        bool
+	maxRuns      int
+	manual       bool
+	lenient      bool
+	errorLimit   time.Duration
+	randomWithin bool
+	debounce     time.Duration
+	heartbeat    time.Duration
+	onHeartbeat  func(time.Time)
+	runOnCancel  bool
+	throttle     time.Duration
+	replace      bool
+
+	// ran is set once the handler was first invoked.
+	ran atomic.Bool
+
+	// delay overrides the next occurrence once, when set by a DelayHandler.
+	delay time.Duration
+
+	// bounce receives the triggers of a debounced task.
+	bounce chan struct{}
+
+	// inflight counts the runs of an async task that have not returned yet.
+	inflight sync.WaitGroup
+
+	// missed are the occurrences to catch up on before the first scheduled run.
+	missed []time.Time
+
+	mu     sync.Mutex
+	err    error
+	reason StopReason
+
+	// cooldown is when a throttled task accepts triggers again, guarded by mu.
+	cooldown time.Time
+
+	// lastReport is when an error was last reported and suppressed counts the errors
+	// left unreported since, guarded by mu.
+	lastReport time.Time
+	suppressed int
+
+	// dependents run after every successful run of the task, guarded by mu.
+	dependents []*dependent
+}
+
+// dependent is a handler chained to the successful runs of a task by OnComplete.
+type dependent struct {
+	handler Handler
+}
+
+// Cancel stops the scheduled execution. It is safe to call more than once.
+func (h *Handle) Cancel() {
+	h.stop(Cancelled, nil)
+}
+
+// Shutdown cancels the task like Cancel, then blocks until its goroutine has exited
+// and no run is in progress. It must not be called from the task's own handler.
+func (h *Handle) Shutdown() {
+	h.Cancel()
+	h.awaitExit()
+}
+
+// ShutdownTimeout stops the task like Shutdown, but waits at most timeout for a run
+// in progress to finish. If it does not, ShutdownTimeout gives up waiting and returns
+// ErrShutdownTimeout; the task is stopped regardless and the run is abandoned to finish
+// on its own. The timeout is measured on the system clock.
+func (h *Handle) ShutdownTimeout(timeout time.Duration) error {
+	h.Cancel()
+
+	exited := make(chan struct{})
+	go func() {
+		h.awaitExit()
+		close(exited)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-exited:
+		return nil
+	case <-timer.C:
+		return ErrShutdownTimeout
+	}
+}
+
+// Close stops the task like Shutdown, implementing io.Closer. It returns the error
+// returned by the handler if it had already failed, and nil otherwise.
+func (h *Handle) Close() error {
+	h.Shutdown()
+	return h.Err()
+}
+
+// awaitExit blocks until the goroutine of a stopped task has exited and no run is in progress.
+func (h *Handle) awaitExit() {
+	// Dispatched tasks have no goroutine of their own, but runs hold runMu.
+	if h.dispatcher != nil {
+		h.runMu.Lock()
+		h.runMu.Unlock()
+	} else {
+		<-h.exited
+	}
+
+	h.inflight.Wait()
+}
+
+// Trigger runs the handler once, out of band, without altering the next scheduled
+// occurrence. The run never overlaps with a scheduled run; triggers requested while
+// one is already pending are coalesced. With WithDebounce, the run happens once
+// triggers have been quiet for the debounce period. With WithThrottle, triggers
+// during the cooldown are ignored.
+func (h *Handle) Trigger() {
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+
+	if h.throttle > 0 && !h.acceptTrigger() {
+		return
+	}
+
+	if h.bounce != nil {
+		select {
+		case h.bounce <- struct{}{}:
+		default:
+		}
+		return
+	}
+
+	h.requestTrigger()
+}
+
+// acceptTrigger reports whether a trigger of a throttled task is outside the cooldown,
+// starting a new cooldown if it is.
+func (h *Handle) acceptTrigger() bool {
+	now := h.clock.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now.Before(h.cooldown) {
+		return false
+	}
+	h.cooldown = now.Add(h.throttle)
+	return true
+}
+
+// beat calls the heartbeat callback every heartbeat interval until the task stops.
+func (h *Handle) beat() {
+	timer := h.clock.NewTimer(h.heartbeat)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-h.done:
+			return
+		case t := <-timer.C():
+			h.onHeartbeat(t)
+			timer.Reset(h.heartbeat)
+		}
+	}
+}
+
+// requestTrigger queues an out of band run, unless one is already pending.
+func (h *Handle) requestTrigger() {
+	select {
+	case h.trigger <- struct{}{}:
+		if h.dispatcher != nil {
+			h.dispatcher.trigger(h)
+		}
+	default:
+	}
+}
+
+// debounceTriggers turns bursts of triggers into a single run, once no trigger has
+// arrived for the debounce period. It returns when the task stops.
+func (h *Handle) debounceTriggers() {
+	var timer Timer
+	var quiet <-chan time.Time
+	for {
+		select {
+		case <-h.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-h.bounce:
+			if timer == nil {
+				timer = h.clock.NewTimer(h.debounce)
+			} else {
+				// Discard an expiry that raced with this trigger.
+				if !timer.Stop() {
+					select {
+					case <-timer.C():
+					default:
+					}
+				}
+				timer.Reset(h.debounce)
+			}
+			quiet = timer.C()
+		case <-quiet:
+			quiet = nil
+			h.requestTrigger()
+		}
+	}
+}
+
+// Advance lets a task scheduled with WithManualAdvance continue to its next occurrence
+// after a run. Occurrences that passed while the task was held are skipped. An advance
+// requested before the task is held applies to the following run; advances requested
+// while one is already pending are coalesced.
+func (h *Handle) Advance() {
+	select {
+	case h.advance <- struct{}{}:
+	default:
+	}
+
+	if h.dispatcher != nil {
+		go h.dispatcher.submit(func() {
+			h.runMu.Lock()
+			defer h.runMu.Unlock()
+
+			if h.parked {
+				h.unpark()
+			}
+		})
+	}
+}
+
+// CancelFunc returns Cancel as a plain function, as returned by Schedule.
+func (h *Handle) CancelFunc() func() {
+	return h.Cancel
+}
+
+// Scheduler returns the scheduler the task was scheduled on.
+func (h *Handle) Scheduler() *Scheduler {
+	return h.scheduler
+}
+
+// Name returns the name of a named job, or an empty string.
+func (h *Handle) Name() string {
+	return h.name
+}
+
+// Tags returns the tags the task was scheduled with.
+func (h *Handle) Tags() []string {
+	return h.tags
+}
+
+// Metadata returns the metadata the task was scheduled with. It must not be modified.
+func (h *Handle) Metadata() map[string]string {
+	return h.metadata
+}
+
+// Expr returns the expression the task was scheduled with, or an empty string.
+func (h *Handle) Expr() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.schedule.Expr
+}
+
+// Kind returns the kind of expression the task's current schedule was created from.
+func (h *Handle) Kind() Kind {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.schedule.Kind
+}
+
+// Reschedule replaces the task's schedule with the given expression. The new schedule
+// takes effect when the next occurrence is computed, after the pending run. Called
+// from the task's own handler, it therefore determines the very next occurrence.
+// The anchor and filters of the current schedule are kept. As when scheduling, the new
+// schedule is computed in the scheduler's location unless expr has a TZ= prefix.
+func (h *Handle) Reschedule(expr string) error {
+	ce, err := parse(expr)
+	if err != nil {
+		return err
+	}
+
+	h.pending.Store(ce)
+	return nil
+}
+
+// Enabled reports whether the task is allowed to fire.
+func (h *Handle) Enabled() bool {
+	return !h.disabled.Load()
+}
+
+// Durations returns the histogram of the handler's execution durations.
+func (h *Handle) Durations() *Histogram {
+	return h.durations
+}
+
+// Done returns a channel that is closed once the task has stopped.
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// WaitAny blocks until one of the tasks has stopped and returns it.
+// It returns nil if no handles are given.
+func WaitAny(handles ...*Handle) *Handle {
+	if len(handles) == 0 {
+		return nil
+	}
+
+	cases := make([]reflect.SelectCase, len(handles))
+	for i, h := range handles {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(h.Done())}
+	}
+
+	chosen, _, _ := reflect.Select(cases)
+	return handles[chosen]
+}
+
+// Err returns the error returned by the handler that stopped the task.
+// It is nil while the task is running or after it stopped for any other reason.
+func (h *Handle) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Reason returns why the task stopped, or Running if it has not stopped yet.
+func (h *Handle) Reason() StopReason {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.reason
+}
+
+// Wait blocks until the task has stopped and returns why, along with the error
+// returned by the handler if it failed.
+func (h *Handle) Wait() (StopReason, error) {
+	<-h.done
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.reason, h.err
+}
+
+// stop records the stop reason and terminal error and closes the done channel, once.
+func (h *Handle) stop(reason StopReason, err error) {
+	h.once.Do(func() {
+		h.mu.Lock()
+		h.reason = reason
+		h.err = err
+		h.mu.Unlock()
+
+		close(h.done) // Close the done channel to stop the goroutine.
+
+		if h.cancelCtx != nil {
+			h.cancelCtx()
+		}
+
+		if h.scheduler != nil {
+			h.scheduler.untrack(h)
+		}
+
+		// Dispatched tasks have no goroutine of their own to run the final run on.
+		if h.dispatcher != nil && h.runOnCancel && reason == Cancelled {
+			h.inflight.Add(1)
+			go func() {
+				defer h.inflight.Done()
+
+				h.runMu.Lock()
+				defer h.runMu.Unlock()
+				h.runFinal()
+			}()
+		}
+	})
+}
+
+// stopped reports whether the task was stopped.
+func (h *Handle) stopped() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// runFinal runs the handler once for a task with WithRunOnCancel that was cancelled
+// before it ever ran. The task has already stopped, so an error is only logged.
+func (h *Handle) runFinal() {
+	if !h.runOnCancel || h.ran.Load() || h.disabled.Load() || h.Reason() != Cancelled {
+		return
+	}
+
+	event := Event{Time: h.clock.Now(), Handle: h}
+	if err := h.invoke(event); err != nil {
+		h.scheduler.logger.Warn("scheduler: final run failed", "job", h.name, "error", err)
+	}
+}
+
+// start launches the goroutine running the task, or hands the task to the
+// dispatcher if one is used.
+func (h *Handle) start() {
+	if h.wrapped == nil {
+		h.wrapped = h.scheduler.wrap(h.handler)
+	}
+	h.drawJitter()
+	h.publishNext()
+
+	// Derive the task's context from its parent, which defaults to the scheduler's.
+	parent := h.parent
+	if parent == nil {
+		parent = h.scheduler.ctx
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+	h.ctx, h.cancelCtx = context.WithCancel(parent)
+	context.AfterFunc(h.ctx, h.Cancel)
+
+	h.scheduler.track(h)
+
+	if h.bounce != nil {
+		go h.debounceTriggers()
+	}
+	if h.heartbeat > 0 {
+		go h.beat()
+	}
+
+	// The parent context may already be cancelled.
+	if h.ctx.Err() != nil {
+		h.stop(Cancelled, nil)
+		if h.dispatcher == nil {
+			h.runFinal()
+		}
+		close(h.exited)
+		return
+	}
+
+	// The source may already be exhausted.
+	if h.next.IsZero() {
+		h.stop(Exhausted, nil)
+		close(h.exited)
+		return
+	}
+
+	// Tasks held by a start barrier wait on their own goroutine.
+	if h.held() {
+		go func() {
+			if !h.awaitStart() {
+				if h.dispatcher == nil {
+					h.runFinal()
+					close(h.exited)
+				}
+				return
+			}
+			h.launch()
+		}()
+		return
+	}
+
+	h.launch()
+}
+
+// launch hands the task to the dispatcher, or starts the goroutine running it.
+func (h *Handle) launch() {
+	if h.dispatcher != nil {
+		if len(h.missed) == 0 {
+			h.dispatcher.add(h, h.due())
+			return
+		}
+
+		go h.dispatcher.submit(func() {
+			h.runMu.Lock()
+			defer h.runMu.Unlock()
+
+			if h.runCatchUp() {
+				h.dispatcher.add(h, h.due())
+			}
+		})
+		return
+	}
+
+	// Create a timer that fires at the next occurrence.
+	timer := h.clock.NewTimer(h.due().Sub(h.clock.Now()))
+
+	go h.run(timer)
+}
+
+// held reports whether the task has to wait for the scheduler's start barrier.
+func (h *Handle) held() bool {
+	if h.scheduler.barrier == nil {
+		return false
+	}
+
+	select {
+	case <-h.scheduler.barrier:
+		return false
+	default:
+		return true
+	}
+}
+
+// awaitStart blocks until the scheduler is started, then anchors the task to the start
+// and determines its first occurrence anew. It reports whether the task is still running.
+func (h *Handle) awaitStart() bool {
+	select {
+	case <-h.scheduler.barrier:
+	case <-h.done:
+		return false
+	}
+
+	h.anchor(h.scheduler.startedAt)
+	h.first(h.scheduler.startedAt)
+	if h.next.IsZero() {
+		h.stop(Exhausted, nil)
+		return false
+	}
+	h.drawJitter()
+	h.publishNext()
+
+	return true
+}
+
+// run executes the handler on every occurrence until the task is stopped.
+func (h *Handle) run(timer Timer) {
+	defer close(h.exited)
+	defer timer.Stop()
+	defer h.runFinal()
+
+	if !h.runCatchUp() {
+		return
+	}
+
+	for {
+		select {
+		case <-h.done:
+			// Exit the goroutine.
+			return
+		case <-h.trigger:
+			if h.stopped() || !h.runTrigger() {
+				return
+			}
+		case t := <-timer.C():
+			// A timer expiring together with Cancel must not start a run afterwards.
+			if h.stopped() || !h.fire(t) {
+				return
+			}
+			if h.manual && !h.awaitAdvance() {
+				return
+			}
+
+			timer.Reset(h.due().Sub(h.clock.Now()))
+		}
+	}
+}
+
+// fire runs the handler for an occurrence happening at t and advances to the next
+// occurrence. It reports whether the task is still running.
+func (h *Handle) fire(t time.Time) bool {
+	// Disabled tasks keep their cadence but skip the run.
+	var finished time.Time
+	if !h.disabled.Load() {
+		event := Event{Time: t, Scheduled: h.next, Missed: h.skipped, Handle: h}
+		h.recordLag(event, t.Sub(h.next)-h.jitter)
+
+		if h.async {
+			h.invokeAsync(event)
+		} else {
+			var err error
+			if finished, err = h.execute(event); err != nil {
+				h.fail(err)
+				return false
+			}
+			if h.reachedMaxRuns() {
+				return false
+			}
+		}
+	}
+
+	// A schedule replaced by Reschedule applies from here on.
+	if ce := h.pending.Swap(nil); ce != nil {
+		h.replaceSchedule(ce)
+	}
+
+	// Update the next occurrence. The end of a synchronous run saves reading the clock
+	// again, which dominates the cost of a tick.
+	if finished.IsZero() {
+		finished = h.clock.Now()
+	}
+	h.next = h.nextOccurrence(finished)
+	if h.next.IsZero() {
+		h.stop(Exhausted, nil)
+		return false
+	}
+	h.drawJitter()
+	h.publishNext()
+
+	return true
+}
+
+// anchor aligns the task's schedule to t. The schedule may be read concurrently, e.g.
+// by Agenda, so a copy is anchored and replaces it.
+func (h *Handle) anchor(t time.Time) {
+	ce := *h.schedule
+	ce.Anchor = t
+
+	h.mu.Lock()
+	h.schedule = &ce
+	h.mu.Unlock()
+}
+
+// replaceSchedule makes ce the task's schedule, keeping the anchor and filters of
+// the current one. Like a new schedule, ce is computed in the scheduler's location
+// unless it has a time zone prefix.
+func (h *Handle) replaceSchedule(ce *Schedule) {
+	ce.Anchor = h.schedule.Anchor
+	if ce.Location == nil {
+		ce.Location = h.scheduler.location
+	}
+	ce.filters = h.schedule.filters
+
+	h.mu.Lock()
+	h.schedule = ce
+	h.mu.Unlock()
+}
+
+// awaitAdvance holds a task with manual advance after a run until Advance is called,
+// still serving triggers. It reports whether the task is still running.
+func (h *Handle) awaitAdvance() bool {
+	for {
+		select {
+		case <-h.done:
+			return false
+		case <-h.trigger:
+			if !h.runTrigger() {
+				return false
+			}
+		case <-h.advance:
+			return h.skipPassed()
+		}
+	}
+}
+
+// unpark queues a dispatched task held by manual advance once Advance was called.
+// It must be called with runMu held.
+func (h *Handle) unpark() {
+	select {
+	case <-h.done:
+		return
+	case <-h.advance:
+	default:
+		return
+	}
+
+	h.parked = false
+	if h.skipPassed() {
+		h.dispatcher.add(h, h.due())
+	}
+}
+
+// skipPassed moves the next occurrence past the current time if it passed while the
+// task was held. It reports whether the task is still running.
+func (h *Handle) skipPassed() bool {
+	now := h.clock.Now()
+	if !h.next.Before(now) {
+		return true
+	}
+
+	h.next = h.nextOccurrence(now)
+	if h.next.IsZero() {
+		h.stop(Exhausted, nil)
+		return false
+	}
+	h.drawJitter()
+	h.publishNext()
+
+	return true
+}
+
+// due returns when the next run should start, which is the next occurrence delayed by the jitter.
+func (h *Handle) due() time.Time {
+	return h.next.Add(h.jitter)
+}
+
+// drawJitter draws the random delay of the next run. The delay is clamped to 90% of
+// the gap to the following occurrence, so a jittered run never slides past it.
+func (h *Handle) drawJitter() {
+	h.jitter = 0
+	if h.randomWithin {
+		h.drawWithin()
+		return
+	}
+	if h.maxJitter <= 0 || h.next.IsZero() {
+		return
+	}
+
+	limit := h.maxJitter
+	if following := h.schedule.NextOccurrence(h.next); !following.IsZero() {
+		gap := following.Sub(h.next)
+		limit = min(limit, gap-gap/10)
+	}
+
+	if limit > 0 {
+		h.jitter = h.scheduler.randN(limit)
+	}
+}
+
+// drawWithin draws the delay of the next run uniformly across the bucket between the
+// next occurrence and the following one, but not before now.
+func (h *Handle) drawWithin() {
+	if h.next.IsZero() {
+		return
+	}
+
+	following := h.schedule.NextOccurrence(h.next)
+	if following.IsZero() {
+		return
+	}
+
+	gap := following.Sub(h.next)
+	earliest := max(h.clock.Now().Sub(h.next), 0)
+	if earliest < gap {
+		h.jitter = earliest + h.scheduler.randN(gap-earliest)
+	}
+}
+
+// nextOccurrence computes the occurrence following the current one, given the
+// current time.
+func (h *Handle) nextOccurrence(now time.Time) time.Time {
+	h.skipped = 0
+
+	// A delay returned by the handler replaces the next occurrence.
+	if h.delay > 0 {
+		next := now.Add(h.delay)
+		h.delay = 0
+		return next
+	}
+
+	// Fixed delay runs are spaced by the delay, but never start before the
+	// previous run has finished.
+	if h.fixedDelay > 0 {
+		next := h.next.Add(h.fixedDelay)
+		if next.Before(now) {
+			next = now
+		}
+		return next
+	}
+
+	// Occurrences that passed while the handler was running are skipped rather
+	// than caught up, so a slow handler cannot cause a burst of runs. They are
+	// counted for the event of the next run.
+	next := h.schedule.NextOccurrence(h.next)
+	for !next.IsZero() && next.Before(now) {
+		next = h.schedule.NextOccurrence(next)
+		h.skipped++
+	}
+
+	return next
+}
+
+// runTrigger runs the handler for an out of band trigger. It reports whether
+// the task is still running.
+func (h *Handle) runTrigger() bool {
+	if h.disabled.Load() {
+		return true
+	}
+
+	event := Event{Time: h.clock.Now(), Handle: h}
+	if err := h.invoke(event); err != nil {
+		h.fail(err)
+		return false
+	}
+
+	return !h.reachedMaxRuns()
+}
+
+// reachedMaxRuns stops the task once it has run the maximum number of times.
+// It reports whether the task was stopped.
+func (h *Handle) reachedMaxRuns() bool {
+	if h.maxRuns <= 0 || h.Stats().Runs < h.maxRuns {
+		return false
+	}
+
+	h.stop(Exhausted, nil)
+	return true
+}
+
+// runContext returns the context of a single run. It is cancelled when the task
+// stops, when the run timeout expires, or when the returned cancel function is called.
+func (h *Handle) runContext() (context.Context, context.CancelFunc) {
+	if h.runTimeout > 0 {
+		return context.WithTimeout(h.ctx, h.runTimeout)
+	}
+	return context.WithCancel(h.ctx)
+}
+
+// fail stops the task after the handler returned err.
+func (h *Handle) fail(err error) {
+	if errors.Is(err, ErrStop) {
+		h.stop(HandlerStopped, nil)
+		return
+	}
+
+	h.stop(HandlerFailed, err)
+}
+
+// invokeAsync runs the handler for the given event like invoke, on its own goroutine.
+func (h *Handle) invokeAsync(event Event) {
+	h.ran.Store(true)
+	h.inflight.Add(1)
+	go func() {
+		defer h.inflight.Done()
+
+		if err := h.invoke(event); err != nil {
+			h.fail(err)
+			return
+		}
+		h.reachedMaxRuns()
+	}()
+}
+
+// invoke runs the handler for the given event and records its duration.
+func (h *Handle) invoke(event Event) error {
+	_, err := h.execute(event)
+	return err
+}
+
+// execute runs the handler like invoke, and also returns when the run finished.
+func (h *Handle) execute(event Event) (finished time.Time, err error) {
+	return h.executeWith(h.wrapped, event)
+}
+
+// executeWith runs handler for event as a run of the task, recording it like execute.
+func (h *Handle) executeWith(handler Handler, event Event) (finished time.Time, err error) {
+	h.scheduler.running.Add(1)
+	defer h.scheduler.running.Add(-1)
+
+	h.ran.Store(true)
+	began := h.clock.Now()
+	err = handler(event)
+	finished = h.clock.Now()
+	h.durations.Observe(finished.Sub(began))
+	h.recordRun(event, began, err)
+	if err == nil {
+		h.succeeded.Store(true)
+
+		// A slow store or chained handler delays the next occurrence like a slow handler,
+		// so the run finishes once they have returned.
+		stored := h.recordLastRun(event.Time)
+		if chained := h.runDependents(event); stored || chained {
+			finished = h.clock.Now()
+		}
+		return finished, nil
+	}
+
+	// Once a lenient task has succeeded, its errors are only reported.
+	if h.lenient && h.succeeded.Load() && !errors.Is(err, ErrStop) {
+		h.reportError(err)
+		return finished, nil
+	}
+	return finished, err
+}
+
+// reportError logs an error that does not stop the task, at most once per
+// error rate limit. The next report counts the errors suppressed since the last one.
+func (h *Handle) reportError(err error) {
+	now := h.clock.Now()
+
+	h.mu.Lock()
+	if h.errorLimit > 0 && !h.lastReport.IsZero() && now.Sub(h.lastReport) < h.errorLimit {
+		h.suppressed++
+		h.mu.Unlock()
+		return
+	}
+	suppressed := h.suppressed
+	h.lastReport, h.suppressed = now, 0
+	h.mu.Unlock()
+
+	if suppressed > 0 {
+		h.scheduler.logger.Warn("scheduler: handler failed", "job", h.name, "error", err, "suppressed", suppressed)
+		return
+	}
+	h.scheduler.logger.Warn("scheduler: handler failed", "job", h.name, "error", err)
+}
+
+// runDependents runs the handlers chained to the task after its successful run of
+// event, and reports whether there were any. A dependent returning ErrStop is
+// unchained; other errors are only logged.
+func (h *Handle) runDependents(event Event) bool {
+	h.mu.Lock()
+	dependents := slices.Clone(h.dependents)
+	h.mu.Unlock()
+
+	for _, d := range dependents {
+		err := d.handler(event)
+		switch {
+		case errors.Is(err, ErrStop):
+			h.removeDependent(d)
+		case err != nil:
+			h.scheduler.logger.Warn("scheduler: dependent failed", "job", h.name, "error", err)
+		}
+	}
+	return len(dependents) > 0
+}
+
+// addDependent chains d to the successful runs of the task.
+func (h *Handle) addDependent(d *dependent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dependents = append(h.dependents, d)
+}
+
+// removeDependent unchains d from the task.
+func (h *Handle) removeDependent(d *dependent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dependents = slices.DeleteFunc(h.dependents, func(other *dependent) bool { return other == d })
+}
@@ -0,0 +1,686 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test Err reports the handler error after the task stops
+func TestHandleErr(t *testing.T) {
+	s := New(time.Now())
+
+	errStop := errors.New("stop execution")
+	h, err := s.ScheduleHandle("@every 20ms", func(event Event) error {
+		return errStop
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if h.Err() != nil {
+		t.Fatalf("Expected nil error while running, got %v", h.Err())
+	}
+
+	select {
+	case <-h.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected task to stop")
+	}
+
+	if !errors.Is(h.Err(), errStop) {
+		t.Fatalf("Expected handler error, got %v", h.Err())
+	}
+	if h.Reason() != HandlerFailed {
+		t.Fatalf("Expected reason %v, got %v", HandlerFailed, h.Reason())
+	}
+}
+
+// Test Err is nil after a clean cancel
+func TestHandleCancelErr(t *testing.T) {
+	s := New(time.Now())
+
+	h, err := s.ScheduleHandle("@every 20ms", func(event Event) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h.Cancel()
+	h.Cancel() // Cancelling twice must be safe.
+
+	<-h.Done()
+	if h.Err() != nil {
+		t.Fatalf("Expected nil error after cancel, got %v", h.Err())
+	}
+}
+
+// Test triggering a run between ticks leaves the cadence unchanged
+func TestHandleTrigger(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event)
+	h, err := s.ScheduleHandle("@every 10s", func(event Event) error {
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	clock.Advance(10 * time.Second)
+	if event := <-events; !event.Time.Equal(start.Add(10 * time.Second)) {
+		t.Fatalf("Expected scheduled event at 10s, got %v", event.Time)
+	}
+
+	clock.Advance(3 * time.Second)
+	h.Trigger()
+	if event := <-events; !event.Time.Equal(start.Add(13 * time.Second)) {
+		t.Fatalf("Expected triggered event at 13s, got %v", event.Time)
+	}
+
+	clock.Advance(7 * time.Second)
+	if event := <-events; !event.Time.Equal(start.Add(20 * time.Second)) {
+		t.Fatalf("Expected scheduled event at 20s, got %v", event.Time)
+	}
+}
+
+// Test occurrences missed by a slow handler are skipped
+func TestHandleSkipsPastOccurrences(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 10)
+	first := true
+	h, err := s.ScheduleHandle("@every 10s", func(event Event) error {
+		events <- event
+		if first {
+			first = false
+			clock.Advance(35 * time.Second) // Simulate a slow run.
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	clock.Advance(10 * time.Second)
+	<-events
+
+	// The clock is at 45s; the occurrences at 20s, 30s and 40s are skipped.
+	time.Sleep(20 * time.Millisecond)
+	if n := len(events); n != 0 {
+		t.Fatalf("Expected no runaway runs, got %d", n)
+	}
+
+	clock.Advance(5 * time.Second)
+	if event := <-events; !event.Time.Equal(start.Add(50 * time.Second)) {
+		t.Fatalf("Expected next run at 50s, got %v", event.Time)
+	}
+}
+
+// Test the occurrences skipped during slow runs are counted on the next event
+func TestEventMissed(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	// Simulated handler durations per run.
+	durations := []time.Duration{35 * time.Second, 20 * time.Second, 0, 0}
+	events := make(chan Event, len(durations))
+	var runs int
+	h, err := s.ScheduleHandle("@every 10s", func(event Event) error {
+		clock.Advance(durations[runs])
+		runs++
+		events <- event
+		return nil
+	}, WithMaxRuns(len(durations)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	waitArmed(t, clock, start.Add(10*time.Second))
+	clock.Advance(10 * time.Second)
+	<-events
+
+	// The first run ends at 45s, past 20s, 30s and 40s.
+	waitArmed(t, clock, start.Add(50*time.Second))
+	clock.Advance(5 * time.Second)
+
+	// The second run ends at 70s, past 60s, and 70s is due right away.
+	want := []struct {
+		scheduled time.Duration
+		missed    int
+	}{
+		{50 * time.Second, 3},
+		{70 * time.Second, 1},
+	}
+	for _, w := range want {
+		event := <-events
+		if !event.Scheduled.Equal(start.Add(w.scheduled)) || event.Missed != w.missed {
+			t.Fatalf("Expected run at %v with %d missed, got %v with %d", w.scheduled, w.missed, event.Scheduled.Sub(start), event.Missed)
+		}
+	}
+
+	waitArmed(t, clock, start.Add(80*time.Second))
+	clock.Advance(10 * time.Second)
+	if event := <-events; event.Missed != 0 {
+		t.Fatalf("Expected no missed occurrences after a quick run, got %d", event.Missed)
+	}
+}
+
+// Test Idle flips while a handler is running
+func TestSchedulerIdle(t *testing.T) {
+	s := New(time.Now())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+		close(started)
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	if !s.Idle() {
+		t.Fatal("Expected scheduler to be idle before any run")
+	}
+
+	h.Trigger()
+	<-started
+	if s.Idle() {
+		t.Fatal("Expected scheduler not to be idle during a run")
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for !s.Idle() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !s.Idle() {
+		t.Fatal("Expected scheduler to be idle after the run")
+	}
+}
+
+// Test returning ErrStop ends the task cleanly
+func TestHandleErrStop(t *testing.T) {
+	s := New(time.Now())
+
+	runs := 0
+	h, err := s.ScheduleHandle("@every 10ms", func(event Event) error {
+		runs++
+		if runs == 3 {
+			return fmt.Errorf("done: %w", ErrStop)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	select {
+	case <-h.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected task to stop")
+	}
+
+	if runs != 3 {
+		t.Fatalf("Expected 3 runs, got %d", runs)
+	}
+	if h.Reason() != HandlerStopped {
+		t.Fatalf("Expected reason %v, got %v", HandlerStopped, h.Reason())
+	}
+	if h.Err() != nil {
+		t.Fatalf("Expected nil error, got %v", h.Err())
+	}
+}
+
+// Test WaitAny returns the first task to stop
+func TestWaitAny(t *testing.T) {
+	s := New(time.Now())
+	defer s.Stop()
+
+	ok := func(event Event) error { return nil }
+	a, _ := s.ScheduleHandle("@every 10ms", ok)
+	b, _ := s.ScheduleHandle("@every 15ms", func(event Event) error {
+		return errors.New("failed")
+	})
+	c, _ := s.ScheduleHandle("@every 20ms", ok)
+
+	if first := WaitAny(a, b, c); first != b {
+		t.Fatal("Expected the failing task to stop first")
+	}
+	if b.Err() == nil {
+		t.Fatal("Expected the failing task to report its error")
+	}
+
+	if WaitAny() != nil {
+		t.Fatal("Expected nil without handles")
+	}
+}
+
+// Test Shutdown returns only after the goroutine has exited
+func TestHandleShutdown(t *testing.T) {
+	s := New(time.Now())
+	base := runtime.NumGoroutine()
+
+	started := make(chan struct{})
+	h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h.Trigger()
+	<-started
+	h.Shutdown()
+
+	if n := runtime.NumGoroutine(); n > base {
+		t.Fatalf("Expected goroutine to have exited, %d remain above baseline", n-base)
+	}
+}
+
+// Test Scheduler.Shutdown waits for in-flight runs
+func TestSchedulerShutdown(t *testing.T) {
+	s := New(time.Now())
+
+	started := make(chan struct{})
+	var finished atomic.Bool
+	h, _ := s.ScheduleHandle("@every 1h", func(event Event) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+		return nil
+	})
+
+	h.Trigger()
+	<-started
+	s.Shutdown()
+
+	if !finished.Load() {
+		t.Fatal("Expected Shutdown to wait for the in-flight run")
+	}
+}
+
+// Test a handler rescheduling its own task from within the run
+func TestHandleRescheduleFromHandler(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 10)
+	runs := 0
+	h, err := s.ScheduleHandle("@every 1m", func(event Event) error {
+		runs++
+		if runs == 2 {
+			// Halve the interval.
+			if err := event.Handle.Reschedule("@every 30s"); err != nil {
+				return err
+			}
+		}
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 150 * time.Second, 3 * time.Minute} {
+		clock.Advance(start.Add(want).Sub(clock.Now()))
+		if event := <-events; !event.Scheduled.Equal(start.Add(want)) {
+			t.Fatalf("Expected run at %v, got %v", start.Add(want), event.Scheduled)
+		}
+	}
+
+	if h.Expr() != "@every 30s" {
+		t.Fatalf("Expected rescheduled expression, got %q", h.Expr())
+	}
+	if err := h.Reschedule("@never"); err == nil {
+		t.Fatal("Expected error for an invalid expression")
+	}
+}
+
+// Test rescheduling uses the scheduler's location unless the expression names a zone
+func TestHandleRescheduleZone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	s := New(start, WithClock(NewFakeClock(start)), WithLocation(time.UTC))
+	h, err := s.ScheduleHandle("@every 1h", func(Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	for _, tt := range []struct {
+		expr string
+		want *time.Location
+	}{
+		{"TZ=America/New_York @daily:09:00", ny},
+		{"@daily:09:00", time.UTC},
+	} {
+		ce, err := parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", tt.expr, err)
+		}
+		h.replaceSchedule(ce)
+		if loc := h.schedule.Location; loc.String() != tt.want.String() {
+			t.Fatalf("Expected location %v for %q, got %v", tt.want, tt.expr, loc)
+		}
+	}
+}
+
+// Test Wait reports why the task stopped
+func TestHandleWait(t *testing.T) {
+	errFail := errors.New("handler failed")
+
+	tests := []struct {
+		name    string
+		handler Handler
+		opts    []JobOption
+		cancel  bool
+		reason  StopReason
+		err     error
+	}{
+		{"cancel", func(event Event) error { return nil }, nil, true, Cancelled, nil},
+		{"error", func(event Event) error { return errFail }, nil, false, HandlerFailed, errFail},
+		{"max runs", func(event Event) error { return nil }, []JobOption{WithMaxRuns(3)}, false, Exhausted, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(time.Now())
+
+			h, err := s.ScheduleHandle("@every 10ms", tt.handler, tt.opts...)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.cancel {
+				h.Cancel()
+			}
+
+			reason, err := h.Wait()
+			if reason != tt.reason {
+				t.Fatalf("Expected reason %v, got %v", tt.reason, reason)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// Test WithMaxRuns stops the task after exactly n runs
+func TestWithMaxRuns(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	var runs atomic.Int32
+	h, err := s.ScheduleHandle("@every 1s", func(event Event) error {
+		runs.Add(1)
+		return nil
+	}, WithMaxRuns(2))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		clock.Advance(time.Second)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if reason, _ := h.Wait(); reason != Exhausted {
+		t.Fatalf("Expected reason %v, got %v", Exhausted, reason)
+	}
+
+	clock.Advance(time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if n := runs.Load(); n != 2 {
+		t.Fatalf("Expected 2 runs, got %d", n)
+	}
+}
+
+// Test ShutdownTimeout gives up on a handler ignoring cancellation
+func TestHandleShutdownTimeout(t *testing.T) {
+	s := New(time.Now())
+
+	running := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	var once sync.Once
+	h, err := s.ScheduleHandle("@every 10ms", func(event Event) error {
+		once.Do(func() { close(running) })
+		<-release // Ignore cancellation.
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	<-running
+
+	began := time.Now()
+	if err := h.ShutdownTimeout(50 * time.Millisecond); !errors.Is(err, ErrShutdownTimeout) {
+		t.Fatalf("Expected %v, got %v", ErrShutdownTimeout, err)
+	}
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Fatalf("Expected a bounded shutdown, took %v", elapsed)
+	}
+	if h.Reason() != Cancelled {
+		t.Fatalf("Expected the task to be marked stopped, got %v", h.Reason())
+	}
+
+	// A task without a run in progress shuts down in time.
+	idle, err := s.ScheduleHandle("@every 1h", func(event Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := idle.ShutdownTimeout(time.Second); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// Test a handle reports the scheduler and name it belongs to
+func TestHandleAccessors(t *testing.T) {
+	s := New(time.Now())
+	other := New(time.Now())
+
+	h, err := s.AddJob("report", "@every 1h", func(Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	anonymous, err := other.ScheduleHandle("@every 1h", func(Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer anonymous.Cancel()
+
+	if h.Scheduler() != s || anonymous.Scheduler() != other {
+		t.Fatal("Expected handles to report the scheduler they were scheduled on")
+	}
+	if h.Name() != "report" || anonymous.Name() != "" {
+		t.Fatalf("Expected names %q and %q, got %q and %q", "report", "", h.Name(), anonymous.Name())
+	}
+
+	cancel := h.CancelFunc()
+	cancel()
+	if reason, _ := h.Wait(); reason != Cancelled {
+		t.Fatalf("Expected reason %v, got %v", Cancelled, reason)
+	}
+}
+
+// Test the handle API behaves the same for equivalent cron and @every schedules
+func TestHandleKinds(t *testing.T) {
+	tests := []struct {
+		expr string
+		kind Kind
+	}{
+		{"@every 1m", KindEvery},
+		{"* * * * *", KindCron},
+	}
+
+	for _, tt := range tests {
+		start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+		clock := NewFakeClock(start)
+		s := New(start, WithClock(clock), WithLocation(time.UTC))
+
+		events := make(chan Event)
+		h, err := s.AddJob("job", tt.expr, func(event Event) error {
+			events <- event
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if h.Kind() != tt.kind || h.Expr() != tt.expr {
+			t.Fatalf("%s: expected kind %v, got %v with expression %q", tt.expr, tt.kind, h.Kind(), h.Expr())
+		}
+		if got := h.Describe(); got != tt.expr+", in UTC" {
+			t.Fatalf("%s: unexpected description %q", tt.expr, got)
+		}
+		if !h.FirstRun().Equal(start.Add(time.Minute)) || !h.NextRun().Equal(start.Add(time.Minute)) {
+			t.Fatalf("%s: expected the first run after a minute, got %v and %v", tt.expr, h.FirstRun(), h.NextRun())
+		}
+		agenda := s.Agenda(start, 2)
+		if len(agenda) != 2 || !agenda[1].Time.Equal(start.Add(2*time.Minute)) || agenda[1].Name != "job" {
+			t.Fatalf("%s: unexpected agenda %+v", tt.expr, agenda)
+		}
+
+		waitArmed(t, clock, start.Add(time.Minute))
+		clock.Advance(time.Minute)
+		if event := <-events; !event.Scheduled.Equal(start.Add(time.Minute)) {
+			t.Fatalf("%s: expected a run at 10:01, got %v", tt.expr, event.Scheduled)
+		}
+		waitArmed(t, clock, start.Add(2*time.Minute))
+		if !h.NextRun().Equal(start.Add(2 * time.Minute)) {
+			t.Fatalf("%s: expected the next run at 10:02, got %v", tt.expr, h.NextRun())
+		}
+
+		h.Trigger()
+		if event := <-events; !event.Scheduled.IsZero() {
+			t.Fatalf("%s: expected a triggered run, got %v", tt.expr, event.Scheduled)
+		}
+		waitRuns(t, h, 2)
+
+		// Rescheduling switches the kind from the next occurrence on.
+		if err := h.Reschedule("@every 1h"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		clock.Advance(time.Minute)
+		<-events
+		waitArmed(t, clock, start.Add(time.Hour))
+		if h.Kind() != KindEvery {
+			t.Fatalf("%s: expected kind %v after rescheduling, got %v", tt.expr, KindEvery, h.Kind())
+		}
+
+		if err := h.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if stats := h.Stats(); stats.Runs != 3 || h.Reason() != Cancelled {
+			t.Fatalf("%s: expected 3 runs and a cancelled task, got %d runs and %v", tt.expr, stats.Runs, h.Reason())
+		}
+	}
+}
+
+// Test the description of a task mentions each option affecting its timing
+func TestDescribe(t *testing.T) {
+	s := New(time.Now(), WithLocation(time.UTC))
+
+	h, err := s.ScheduleHandle("@every 5m", func(Event) error { return nil },
+		WithAlignFirst(),
+		WithJitter(10*time.Second),
+		WithActiveWindow(9*time.Hour, 17*time.Hour),
+		WithExcludeDates(time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC)),
+		WithThrottle(time.Minute),
+		WithMaxRuns(3),
+		WithDisabled(),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	want := "@every 5m, aligned, jitter up to 10s, active 09:00-17:00, excluding 1 dates, throttle 1m0s, at most 3 runs, disabled, in UTC"
+	if got := h.Describe(); got != want {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+
+	// Tasks created without an expression describe their interval.
+	h, err = s.schedule(&Schedule{Kind: KindEvery, Frequency: 90 * time.Second}, func(Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+	if got := h.Describe(); got != "@every 1m30s, in UTC" {
+		t.Fatalf("Expected %q, got %q", "@every 1m30s, in UTC", got)
+	}
+}
+
+// Test Close stops the task and reports a terminal error
+func TestHandleClose(t *testing.T) {
+	s := New(time.Now())
+
+	// A running task closes cleanly, once the run in progress finished.
+	var running atomic.Bool
+	started := make(chan struct{}, 1)
+	func() {
+		h, err := s.ScheduleHandle("@every 10ms", func(Event) error {
+			running.Store(true)
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Store(false)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var closer io.Closer = h
+		defer func() {
+			if err := closer.Close(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if running.Load() || h.Reason() != Cancelled {
+				t.Fatalf("Expected the task to be cancelled and drained, got %v", h.Reason())
+			}
+		}()
+		<-started
+	}()
+
+	// A failed task reports the handler's error.
+	errFail := errors.New("boom")
+	h, err := s.ScheduleHandle("@every 10ms", func(Event) error { return errFail })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	h.Wait()
+	if err := h.Close(); !errors.Is(err, errFail) {
+		t.Fatalf("Expected %v, got %v", errFail, err)
+	}
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of the buckets used to record handler durations.
+var DefaultBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// Histogram is a fixed-bucket histogram of durations. It is safe for concurrent use.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []time.Duration
+	counts []uint64
+	total  uint64
+	sum    time.Duration
+}
+
+// Bucket is the number of observations less than or equal to UpperBound and
+// greater than the previous bucket's bound. The last bucket has no upper bound
+// and reports math.MaxInt64.
+type Bucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// NewHistogram creates a Histogram with the given bucket upper bounds.
+func NewHistogram(bounds ...time.Duration) *Histogram {
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe records a duration.
+func (h *Histogram) Observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts[i]++
+	h.total++
+	h.sum += d
+}
+
+// Buckets returns the count of every bucket, including the overflow bucket.
+func (h *Histogram) Buckets() []Bucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := make([]Bucket, len(h.counts))
+	for i, count := range h.counts {
+		buckets[i] = Bucket{UpperBound: math.MaxInt64, Count: count}
+		if i < len(h.bounds) {
+			buckets[i].UpperBound = h.bounds[i]
+		}
+	}
+	return buckets
+}
+
+// Count returns the total number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total
+}
+
+// Mean returns the average of all observations.
+func (h *Histogram) Mean() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.total)
+}
+
+// Percentile returns the upper bound of the bucket containing the p-th percentile,
+// with p between 0 and 100. It returns 0 when there are no observations.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p / 100 * float64(h.total)))
+	var seen uint64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank && i < len(h.bounds) {
+			return h.bounds[i]
+		}
+	}
+	return math.MaxInt64
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// Test bucket counts for synthetic durations
+func TestHistogramBuckets(t *testing.T) {
+	h := NewHistogram(10*time.Millisecond, time.Millisecond, 100*time.Millisecond)
+
+	for _, d := range []time.Duration{
+		500 * time.Microsecond,
+		time.Millisecond,
+		5 * time.Millisecond,
+		50 * time.Millisecond,
+		80 * time.Millisecond,
+		time.Second,
+	} {
+		h.Observe(d)
+	}
+
+	want := []Bucket{
+		{time.Millisecond, 2},
+		{10 * time.Millisecond, 1},
+		{100 * time.Millisecond, 2},
+		{math.MaxInt64, 1},
+	}
+
+	got := h.Buckets()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d buckets, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected bucket %d to be %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	if h.Count() != 6 {
+		t.Fatalf("Expected 6 observations, got %d", h.Count())
+	}
+	if p := h.Percentile(50); p != 10*time.Millisecond {
+		t.Fatalf("Expected median bucket 10ms, got %v", p)
+	}
+	if p := h.Percentile(100); p != math.MaxInt64 {
+		t.Fatalf("Expected max in overflow bucket, got %v", p)
+	}
+}
+
+// Test handler durations are recorded on the handle
+func TestHandleDurations(t *testing.T) {
+	s := New(time.Now())
+
+	h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	h.Trigger()
+	time.Sleep(100 * time.Millisecond)
+
+	buckets := h.Durations().Buckets()
+	if h.Durations().Count() != 1 || buckets[2].Count != 1 {
+		t.Fatalf("Expected one run in the 100ms bucket, got %v", buckets)
+	}
+}
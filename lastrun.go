@@ -0,0 +1,82 @@
+package scheduler
+
+import "time"
+
+// LastRunStore persists the time of the last successful run of named jobs, so that
+// occurrences missed while the process was down can be caught up on restart.
+type LastRunStore interface {
+	// Get returns the last successful run of the named job, or the zero time if
+	// none was recorded.
+	Get(name string) (time.Time, error)
+	// Set records t as the last successful run of the named job.
+	Set(name string, t time.Time) error
+}
+
+// WithLastRunStore records the successful runs of jobs added with AddJob in store.
+// When a job is added, the occurrences it missed since its recorded last run are
+// run right away, up to the limit set by WithCatchUp.
+func WithLastRunStore(store LastRunStore) Option {
+	return func(s *Scheduler) {
+		s.lastRuns = store
+	}
+}
+
+// missedOccurrences returns the most recent occurrences of the job after its last
+// recorded run and up to now, at most h.catchUp of them.
+func (h *Handle) missedOccurrences(last, now time.Time) []time.Time {
+	if h.catchUp <= 0 || last.IsZero() {
+		return nil
+	}
+
+	var missed []time.Time
+	for next := h.schedule.nextAfter(last); !next.IsZero() && !next.After(now); next = h.schedule.NextOccurrence(next) {
+		if len(missed) == h.catchUp {
+			missed = missed[1:]
+		}
+		missed = append(missed, next)
+	}
+	return missed
+}
+
+// runCatchUp runs the handler once for every missed occurrence, oldest first.
+// It reports whether the task is still running.
+func (h *Handle) runCatchUp() bool {
+	for len(h.missed) > 0 {
+		occurrence := h.missed[0]
+		h.missed = h.missed[1:]
+
+		select {
+		case <-h.done:
+			return false
+		default:
+		}
+
+		if h.disabled.Load() {
+			continue
+		}
+
+		event := Event{Time: h.clock.Now(), Scheduled: occurrence, Handle: h}
+		if err := h.invoke(event); err != nil {
+			h.fail(err)
+			return false
+		}
+		if h.reachedMaxRuns() {
+			return false
+		}
+	}
+	return true
+}
+
+// recordLastRun stores the time of a successful run of a named job, and reports
+// whether the job has a store to record it in.
+func (h *Handle) recordLastRun(t time.Time) bool {
+	store := h.scheduler.lastRuns
+	if store == nil || h.name == "" {
+		return false
+	}
+
+	if err := store.Set(h.name, t); err != nil {
+		h.scheduler.logger.Warn("scheduler: failed to record last run", "job", h.name, "error", err)
+	}
+	return true
+}
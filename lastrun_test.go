@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory LastRunStore.
+type memoryStore struct {
+	mu   sync.Mutex
+	runs map[string]time.Time
+}
+
+func (m *memoryStore) Get(name string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.runs[name], nil
+}
+
+func (m *memoryStore) Set(name string, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs[name] = t
+	return nil
+}
+
+// Test a missed occurrence is caught up once after downtime
+func TestLastRunStoreCatchUp(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	store := &memoryStore{runs: map[string]time.Time{
+		// The process went down after the 07:00 run.
+		"report": start.Add(-3 * time.Hour),
+	}}
+
+	clock := NewFakeClock(start.Add(30 * time.Minute))
+	s := New(start, WithClock(clock), WithLastRunStore(store))
+
+	events := make(chan Event, 10)
+	h, err := s.AddJob("report", "@hourly", func(event Event) error {
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	// Only the most recent missed occurrence runs.
+	event := <-events
+	if want := start; !event.Scheduled.Equal(want) {
+		t.Fatalf("Expected catch-up of %v, got %v", want, event.Scheduled)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if n := len(events); n != 0 {
+		t.Fatalf("Expected a single catch-up run, got %d more", n)
+	}
+
+	// The successful run is recorded.
+	if last, _ := store.Get("report"); !last.Equal(clock.Now()) {
+		t.Fatalf("Expected last run %v, got %v", clock.Now(), last)
+	}
+
+	// Regular runs resume afterwards.
+	clock.Advance(30 * time.Minute)
+	if event := <-events; !event.Scheduled.Equal(start.Add(time.Hour)) {
+		t.Fatalf("Expected run at %v, got %v", start.Add(time.Hour), event.Scheduled)
+	}
+}
+
+// Test WithCatchUp runs up to N missed occurrences, oldest first
+func TestLastRunStoreCatchUpN(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	store := &memoryStore{runs: map[string]time.Time{"report": start.Add(-5 * time.Hour)}}
+
+	clock := NewFakeClock(start.Add(30 * time.Minute))
+	s := New(start, WithClock(clock), WithLastRunStore(store))
+
+	events := make(chan Event, 10)
+	h, err := s.AddJob("report", "@hourly", func(event Event) error {
+		events <- event
+		return nil
+	}, WithCatchUp(3))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	for _, want := range []time.Time{start.Add(-2 * time.Hour), start.Add(-time.Hour), start} {
+		if event := <-events; !event.Scheduled.Equal(want) {
+			t.Fatalf("Expected catch-up of %v, got %v", want, event.Scheduled)
+		}
+	}
+}
+
+// Test jobs without a recorded run or without missed occurrences do not catch up
+func TestLastRunStoreNoCatchUp(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	store := &memoryStore{runs: map[string]time.Time{"recent": start.Add(10 * time.Minute)}}
+
+	clock := NewFakeClock(start.Add(30 * time.Minute))
+	s := New(start, WithClock(clock), WithLastRunStore(store))
+
+	events := make(chan Event, 10)
+	for _, name := range []string{"new", "recent"} {
+		h, err := s.AddJob(name, "@hourly", func(event Event) error {
+			events <- event
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer h.Cancel()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if n := len(events); n != 0 {
+		t.Fatalf("Expected no catch-up runs, got %d", n)
+	}
+}
@@ -0,0 +1,299 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// JobOption configures a single scheduled task.
+type JobOption func(*Handle)
+
+// ErrIncompatibleOptions is returned when scheduling a task with job options that
+// contradict each other, or by NewValidated for contradicting scheduler options.
+// The incompatible pairs are:
+//   - WithFixedDelay and WithAlignFirst, WithAsyncDispatch or WithRandomWithinInterval,
+//     since fixed delays space runs by the delay from the previous occurrence, never
+//     before the previous run has finished, rather than on the schedule's boundaries.
+//   - WithInitialDelay and WithAlignFirst, which both place the first run.
+//   - WithJitter and WithRandomWithinInterval, which both randomize the runs.
+//   - WithDispatcher and WithClock, since dispatchers always use the system clock.
+var ErrIncompatibleOptions = errors.New("incompatible options")
+
+// validate checks that the job options of the task do not contradict each other.
+func (h *Handle) validate() error {
+	conflicts := []struct {
+		a, b     string
+		conflict bool
+	}{
+		{"WithFixedDelay", "WithAlignFirst", h.fixedDelay > 0 && h.alignFirst},
+		{"WithFixedDelay", "WithAsyncDispatch", h.fixedDelay > 0 && h.async},
+		{"WithFixedDelay", "WithRandomWithinInterval", h.fixedDelay > 0 && h.randomWithin},
+		{"WithInitialDelay", "WithAlignFirst", h.initialDelay > 0 && h.alignFirst},
+		{"WithJitter", "WithRandomWithinInterval", h.maxJitter > 0 && h.randomWithin},
+	}
+	for _, c := range conflicts {
+		if c.conflict {
+			return fmt.Errorf("%w: %s and %s", ErrIncompatibleOptions, c.a, c.b)
+		}
+	}
+	return nil
+}
+
+// WithFixedDelay spaces consecutive runs by d instead of following the expression
+// after the first occurrence. A run that takes longer than d is followed by the
+// next run immediately, so runs never overlap and are never skipped.
+func WithFixedDelay(d time.Duration) JobOption {
+	return func(h *Handle) {
+		h.fixedDelay = d
+	}
+}
+
+// WithAlignFirst snaps the first occurrence of an @every schedule up to the next
+// multiple of its interval, so e.g. an hourly task started at 03:17 first fires at
+// 04:00 and stays on the hour afterwards. Intervals dividing a day are aligned to
+// midnight in the scheduler's location, others to the Unix epoch. Aligned tasks share
+// that origin, so intervals that are multiples of each other, such as 1m, 5m and 15m,
+// fire together, even on schedulers started at different times.
+func WithAlignFirst() JobOption {
+	return func(h *Handle) {
+		h.alignFirst = true
+	}
+}
+
+// WithInitialDelay makes the first run happen d after the task is scheduled,
+// instead of at the first occurrence of the expression. Later runs follow the
+// expression from that first run onwards.
+func WithInitialDelay(d time.Duration) JobOption {
+	return func(h *Handle) {
+		h.initialDelay = d
+	}
+}
+
+// WithSkipFirst silently skips the first n occurrences of the task, so it first fires
+// at occurrence n+1. Unlike WithInitialDelay, the delay is counted in occurrences and
+// the runs stay aligned to the schedule.
+func WithSkipFirst(n int) JobOption {
+	return func(h *Handle) {
+		h.skipFirst = n
+	}
+}
+
+// WithRunTimeout bounds the context of every run of a task scheduled with
+// ScheduleContext. The handler is expected to return once its context is done.
+func WithRunTimeout(d time.Duration) JobOption {
+	return func(h *Handle) {
+		h.runTimeout = d
+	}
+}
+
+// WithBatching accumulates the events of a task scheduled with ScheduleBatch until
+// maxSize events are pending or the oldest pending event is maxWait old, then hands
+// them to the batch handler at once. A batch reaching maxWait between occurrences is
+// flushed by a timer in a run of its own, which counts towards the task's stats,
+// history and WithMaxRuns like a triggered run. Events still pending when the task
+// stops are dropped.
+func WithBatching(maxWait time.Duration, maxSize int) JobOption {
+	return func(h *Handle) {
+		h.batchWait = maxWait
+		h.batchSize = maxSize
+	}
+}
+
+// WithExcludeDates skips every occurrence falling on one of the calendar dates of the
+// given times, e.g. holidays. Dates are compared in the scheduler's location.
+func WithExcludeDates(dates ...time.Time) JobOption {
+	return func(h *Handle) {
+		h.schedule.Exclude(dates...)
+	}
+}
+
+// WithBusinessDays skips every occurrence falling on a weekend or on one of the calendar
+// dates of the given holidays, so e.g. a daily task advances by business days only.
+// Dates are compared in the scheduler's location. A task firing only on weekends, such
+// as @weekends, has no business day occurrences and stops with reason Exhausted.
+func WithBusinessDays(holidays ...time.Time) JobOption {
+	return func(h *Handle) {
+		h.schedule.BusinessDays(holidays...)
+	}
+}
+
+// WithActiveWindow only lets a task fire while the time of day in the scheduler's
+// location is within [start, end), both given as offsets from midnight. Occurrences
+// outside the window are skipped to the first occurrence once it opens again; interval
+// schedules restart their cadence at the opening, so they fire when it opens and every
+// interval after that, whenever the task was scheduled. A window whose end is before
+// its start wraps around midnight. A task whose window allows none of its occurrences
+// stops with reason Exhausted.
+func WithActiveWindow(start, end time.Duration) JobOption {
+	return func(h *Handle) {
+		h.schedule.Window(start, end)
+	}
+}
+
+// WithDisabled schedules a task disabled, so it tracks its cadence but does not fire
+// until it is enabled.
+func WithDisabled() JobOption {
+	return func(h *Handle) {
+		h.disabled.Store(true)
+	}
+}
+
+// WithLagThreshold calls fn before every scheduled run starting more than threshold
+// after its occurrence. A high lag indicates the scheduler is overloaded.
+func WithLagThreshold(threshold time.Duration, fn func(event Event, lag time.Duration)) JobOption {
+	return func(h *Handle) {
+		h.lagThreshold = threshold
+		h.onLag = fn
+	}
+}
+
+// WithJitter delays every run by a random offset in [0, max), to spread the load of
+// tasks sharing a cadence. The offset never changes the occurrences themselves, and it
+// is clamped to 90% of the gap to the following occurrence so that a jittered run never
+// slides past the next one, which would cause that occurrence to be skipped.
+func WithJitter(max time.Duration) JobOption {
+	return func(h *Handle) {
+		h.maxJitter = max
+	}
+}
+
+// WithCatchUp sets how many occurrences missed while the process was down are run
+// when a job is added to a scheduler with a LastRunStore. Only the most recent n
+// missed occurrences are run; n <= 0 disables catching up. It defaults to 1.
+func WithCatchUp(n int) JobOption {
+	return func(h *Handle) {
+		h.catchUp = n
+	}
+}
+
+// WithAsyncDispatch runs every scheduled invocation of the handler on its own goroutine,
+// so a slow handler does not delay the following occurrences. Runs may then overlap
+// and the handler must be safe for concurrent use. Out of band triggers still run
+// inline. By default runs are synchronous and never overlap. Batched tasks ignore it.
+func WithAsyncDispatch() JobOption {
+	return func(h *Handle) {
+		h.async = true
+	}
+}
+
+// WithMaxRuns stops the task with reason Exhausted once its handler has run n times,
+// counting failed and triggered runs. n <= 0 means no limit.
+func WithMaxRuns(n int) JobOption {
+	return func(h *Handle) {
+		h.maxRuns = n
+	}
+}
+
+// WithHistory keeps the last k events of the task, with the error the handler returned
+// for each, for RecentEvents. Older events are evicted.
+func WithHistory(k int) JobOption {
+	return func(h *Handle) {
+		h.historySize = k
+	}
+}
+
+// WithTags tags the task, so related tasks can be cancelled together with CancelByTag.
+func WithTags(tags ...string) JobOption {
+	return func(h *Handle) {
+		h.tags = append(h.tags, tags...)
+	}
+}
+
+// WithMetadata attaches key-value pairs to the task, e.g. the endpoint a job polls,
+// which handlers read with Event.Metadata. The map is copied; later options add to it.
+func WithMetadata(metadata map[string]string) JobOption {
+	return func(h *Handle) {
+		if h.metadata == nil {
+			h.metadata = make(map[string]string, len(metadata))
+		}
+		maps.Copy(h.metadata, metadata)
+	}
+}
+
+// WithManualAdvance holds the task after every scheduled run until Advance is called on
+// its handle, turning it into a step-driven machine, e.g. for integration tests. The
+// first occurrence fires as usual.
+func WithManualAdvance() JobOption {
+	return func(h *Handle) {
+		h.manual = true
+	}
+}
+
+// WithStopUntilFirstSuccess stops the task when the handler fails before it ever
+// succeeded, so a misconfigured job fails fast. After the first successful run, errors
+// are logged as warnings and counted in Stats, and the task keeps running.
+// Returning ErrStop still stops the task.
+func WithStopUntilFirstSuccess() JobOption {
+	return func(h *Handle) {
+		h.lenient = true
+	}
+}
+
+// WithErrorRateLimit reports the errors that WithStopUntilFirstSuccess lets a task
+// keep running after at most once per d, whether they come from scheduled, triggered
+// or async runs. The next report carries the number of errors suppressed in between
+// as its "suppressed" attribute. Errors stopping the task are not rate limited.
+func WithErrorRateLimit(d time.Duration) JobOption {
+	return func(h *Handle) {
+		h.errorLimit = d
+	}
+}
+
+// WithDebounce coalesces bursts of Trigger calls: the handler runs once, after no
+// trigger has arrived for d. Scheduled occurrences are not affected.
+func WithDebounce(d time.Duration) JobOption {
+	return func(h *Handle) {
+		h.debounce = d
+		h.bounce = make(chan struct{}, 1)
+	}
+}
+
+// WithHeartbeat calls fn with the current time every interval while the task is
+// running, independently of its occurrences and runs, e.g. to prove to a liveness
+// monitor that a task with a long interval such as @yearly is still scheduled.
+// The heartbeats stop when the task stops.
+func WithHeartbeat(interval time.Duration, fn func(time.Time)) JobOption {
+	return func(h *Handle) {
+		h.heartbeat = interval
+		h.onHeartbeat = fn
+	}
+}
+
+// WithThrottle makes Trigger run the handler right away, then ignore further triggers
+// for d, i.e. a leading-edge throttle. Scheduled occurrences are not affected.
+func WithThrottle(d time.Duration) JobOption {
+	return func(h *Handle) {
+		h.throttle = d
+	}
+}
+
+// WithReplaceExisting makes AddJob replace a job already registered under the same
+// name, cancelling it, instead of failing with ErrJobExists. Other ways of scheduling
+// ignore it.
+func WithReplaceExisting() JobOption {
+	return func(h *Handle) {
+		h.replace = true
+	}
+}
+
+// WithRunOnCancel controls what happens to a task cancelled before it ever ran. If
+// run is true, the handler runs once on cancellation, e.g. for handlers that also
+// flush or finalize; the event has no Scheduled time and the run's context is already
+// cancelled. By default such a task never runs.
+func WithRunOnCancel(run bool) JobOption {
+	return func(h *Handle) {
+		h.runOnCancel = run
+	}
+}
+
+// WithRandomWithinInterval runs the task once per interval bucket, at a uniformly random
+// instant between an occurrence and the following one, e.g. to spread cache refreshes
+// while guaranteeing one per hour. Runs never start before the task was scheduled, and
+// Event.Scheduled is the start of the bucket. It replaces WithJitter.
+func WithRandomWithinInterval() JobOption {
+	return func(h *Handle) {
+		h.randomWithin = true
+	}
+}
@@ -0,0 +1,1052 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test fixed delay spacing for handlers shorter and longer than the delay
+func TestWithFixedDelay(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	// Simulated handler durations per run.
+	durations := []time.Duration{8 * time.Second, time.Second, 0}
+
+	events := make(chan Event)
+	run := 0
+	cancel, err := s.Schedule("@every 5s", func(event Event) error {
+		events <- event
+		clock.Advance(durations[run])
+		run++
+		return nil
+	}, WithFixedDelay(5*time.Second))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	clock.Advance(5 * time.Second)
+	if event := <-events; !event.Time.Equal(start.Add(5 * time.Second)) {
+		t.Fatalf("Expected first run at 5s, got %v", event.Time)
+	}
+
+	// The first run took longer than the delay, so the next one starts right away.
+	if event := <-events; !event.Time.Equal(start.Add(13 * time.Second)) {
+		t.Fatalf("Expected second run at 13s, got %v", event.Time)
+	}
+
+	// The second run took less than the delay, so the spacing is kept.
+	// The clock is at 14s once it finishes.
+	clock.Advance(3 * time.Second)
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no run before 18s, got %v", event.Time)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	if event := <-events; !event.Time.Equal(start.Add(18 * time.Second)) {
+		t.Fatalf("Expected third run at 18s, got %v", event.Time)
+	}
+}
+
+// Test the first occurrence is snapped to the next interval boundary
+func TestWithAlignFirst(t *testing.T) {
+	start := time.Date(2024, 3, 8, 3, 17, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event)
+	cancel, err := s.Schedule("@every 1h", func(event Event) error {
+		events <- event
+		return nil
+	}, WithAlignFirst())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	for _, want := range []time.Time{
+		time.Date(2024, 3, 8, 4, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 8, 5, 0, 0, 0, time.UTC),
+	} {
+		clock.Advance(want.Sub(clock.Now()))
+		if event := <-events; !event.Time.Equal(want) || !event.Scheduled.Equal(want) {
+			t.Fatalf("Expected run at %v, got %v scheduled at %v", want, event.Time, event.Scheduled)
+		}
+	}
+}
+
+// Test alignment of intervals that do not divide a day
+func TestAlignAfter(t *testing.T) {
+	at := time.Date(2024, 3, 8, 3, 17, 0, 0, time.UTC)
+
+	if got := alignAfter(at, 15*time.Minute); !got.Equal(time.Date(2024, 3, 8, 3, 30, 0, 0, time.UTC)) {
+		t.Fatalf("Expected 03:30, got %v", got)
+	}
+
+	got := alignAfter(at, 7*time.Hour)
+	if got.Unix()%int64((7*time.Hour).Seconds()) != 0 || !got.After(at) || got.Sub(at) > 7*time.Hour {
+		t.Fatalf("Expected next epoch-aligned 7h boundary, got %v", got)
+	}
+}
+
+// Test the first gap differs from the recurring interval
+func TestScheduleWithDelay(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event)
+	cancel, err := s.ScheduleWithDelay(5*time.Second, "@every 5m", func(event Event) error {
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	clock.Advance(5 * time.Second)
+	first := <-events
+	if !first.Time.Equal(start.Add(5 * time.Second)) {
+		t.Fatalf("Expected first run after 5s, got %v", first.Time)
+	}
+
+	clock.Advance(5 * time.Minute)
+	second := <-events
+	if gap := second.Scheduled.Sub(first.Scheduled); gap != 5*time.Minute {
+		t.Fatalf("Expected second gap of 5m, got %v", gap)
+	}
+}
+
+// Test ScheduleWithDelay leaves spare capacity of the caller's options untouched
+func TestScheduleWithDelayOptions(t *testing.T) {
+	s := New(time.Now())
+
+	opts := make([]JobOption, 1, 2)
+	opts[0] = WithTags("delayed")
+	cancel, err := s.ScheduleWithDelay(time.Hour, "@every 1h", func(Event) error { return nil }, opts...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	if opts[:2][1] != nil {
+		t.Fatal("Expected the caller's backing array to be left alone")
+	}
+}
+
+// Test skipped occurrences delay the first run by whole intervals
+func TestWithSkipFirst(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event)
+	h, err := s.ScheduleHandle("@every 100ms", func(event Event) error {
+		events <- event
+		return nil
+	}, WithSkipFirst(2))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	if first := h.FirstRun(); !first.Equal(start.Add(300 * time.Millisecond)) {
+		t.Fatalf("Expected the first run at the 3rd interval, got %v", first)
+	}
+
+	// Nothing fires for the skipped intervals, then runs stay on the grid.
+	waitArmed(t, clock, start.Add(300*time.Millisecond))
+	clock.Advance(300 * time.Millisecond)
+	if event := <-events; !event.Scheduled.Equal(start.Add(300 * time.Millisecond)) {
+		t.Fatalf("Expected the first run at 300ms, got %v", event.Scheduled)
+	}
+
+	waitArmed(t, clock, start.Add(400*time.Millisecond))
+	clock.Advance(100 * time.Millisecond)
+	if event := <-events; !event.Scheduled.Equal(start.Add(400 * time.Millisecond)) {
+		t.Fatalf("Expected the second run at 400ms, got %v", event.Scheduled)
+	}
+}
+
+// Test batching by size and by time, flushing a batch as soon as it is maxWait old
+func TestWithBatching(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxWait time.Duration
+		maxSize int
+		flushes map[time.Duration]int // Batch size flushed at each offset from start.
+	}{
+		{"size", time.Hour, 3, map[time.Duration]int{3 * time.Second: 3, 6 * time.Second: 3}},
+		{"time", 2500 * time.Millisecond, 100, map[time.Duration]int{3500 * time.Millisecond: 3, 6500 * time.Millisecond: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+			clock := NewFakeClock(start)
+			s := New(start, WithClock(clock))
+
+			batches := make(chan []Event)
+			cancel, err := s.ScheduleBatch("@every 1s", func(events []Event) error {
+				batches <- events
+				return nil
+			}, WithBatching(tt.maxWait, tt.maxSize))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer cancel()
+
+			waitArmed(t, clock, start.Add(time.Second))
+			for offset := 500 * time.Millisecond; offset <= 7*time.Second; offset += 500 * time.Millisecond {
+				clock.Advance(500 * time.Millisecond)
+
+				if size, ok := tt.flushes[offset]; ok {
+					select {
+					case got := <-batches:
+						if len(got) != size {
+							t.Fatalf("Expected batch of %d events at %v, got %d", size, offset, len(got))
+						}
+						if age := clock.Now().Sub(got[0].Time); age > tt.maxWait {
+							t.Fatalf("Expected the oldest event at most %v old, got %v", tt.maxWait, age)
+						}
+					case <-time.After(time.Second):
+						t.Fatalf("Expected a batch at %v", offset)
+					}
+				}
+
+				// Wait for the run to finish, re-arming the task for the next second.
+				if offset%time.Second == 0 {
+					waitArmed(t, clock, start.Add(offset+time.Second))
+				}
+			}
+		})
+	}
+}
+
+// Test a batch flushed by its timer counts as a run of the task
+func TestWithBatchingTimerRun(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	batches := make(chan []Event, 1)
+	_, err := s.ScheduleBatch("@every 1h", func(events []Event) error {
+		batches <- events
+		return nil
+	}, WithBatching(time.Second, 100), WithMaxRuns(2))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The occurrence is the first run, and the flush a second later the second.
+	waitArmed(t, clock, start.Add(time.Hour))
+	clock.Advance(time.Hour)
+	waitArmed(t, clock, start.Add(time.Hour+time.Second))
+	clock.Advance(time.Second)
+	if got := <-batches; len(got) != 1 {
+		t.Fatalf("Expected a batch of 1 event, got %d", len(got))
+	}
+
+	// The second run reaches the maximum, stopping the task.
+	deadline := time.Now().Add(time.Second)
+	for s.Snapshot().Tasks != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the task to stop after its flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !s.Idle() {
+		t.Fatal("Expected the scheduler to be idle")
+	}
+}
+
+// Test jitter larger than the interval is clamped so no occurrence is lost
+func TestWithJitterClamped(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event)
+	h, err := s.ScheduleHandle("@every 100ms", func(event Event) error {
+		events <- event
+		return nil
+	}, WithJitter(time.Second))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	// Each occurrence fires before the following one is due. The clock advances only
+	// once the task waits for its next occurrence, or it may skip it.
+	clock.BlockUntilWaiters(1)
+	clock.Advance(100 * time.Millisecond)
+	for i := 1; i <= 50; i++ {
+		clock.BlockUntilWaiters(1)
+		clock.Advance(100 * time.Millisecond)
+		select {
+		case event := <-events:
+			if want := start.Add(time.Duration(i) * 100 * time.Millisecond); !event.Scheduled.Equal(want) {
+				t.Fatalf("Expected occurrence %v, got %v", want, event.Scheduled)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected occurrence %d to fire", i)
+		}
+	}
+
+	if lag := h.Stats().MaxLag; lag >= 100*time.Millisecond {
+		t.Fatalf("Expected lag excluding jitter below the interval, got %v", lag)
+	}
+}
+
+// Test ticks keep coming while an async handler is still running
+func TestWithAsyncDispatch(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 10)
+	release := make(chan struct{})
+	h, err := s.ScheduleHandle("@every 1s", func(event Event) error {
+		events <- event
+		<-release
+		return nil
+	}, WithAsyncDispatch())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Every run blocks, yet each occurrence starts on time.
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Second)
+		select {
+		case event := <-events:
+			if want := start.Add(time.Duration(i) * time.Second); !event.Scheduled.Equal(want) {
+				t.Fatalf("Expected occurrence %v, got %v", want, event.Scheduled)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected occurrence %d to fire while earlier runs are blocked", i)
+		}
+	}
+
+	// Shutdown waits for the runs still in progress.
+	stopped := make(chan struct{})
+	go func() {
+		h.Shutdown()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Expected Shutdown to wait for the running handlers")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	<-stopped
+}
+
+// Test identical seeds produce identical jitter offsets across tasks
+func TestWithRandSeed(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+
+	offsets := func(seed uint64) []time.Duration {
+		s := New(start, WithClock(NewFakeClock(start)), WithRand(rand.New(rand.NewPCG(seed, seed))))
+
+		var handles []*Handle
+		for i := 0; i < 3; i++ {
+			h, err := s.ScheduleHandle("@every 1m", func(event Event) error {
+				return nil
+			}, WithJitter(30*time.Second))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			h.Shutdown()
+			handles = append(handles, h)
+		}
+
+		var offsets []time.Duration
+		for _, h := range handles {
+			offsets = append(offsets, h.jitter)
+			for i := 0; i < 3; i++ {
+				h.drawJitter()
+				offsets = append(offsets, h.jitter)
+			}
+		}
+		return offsets
+	}
+
+	first, second := offsets(42), offsets(42)
+	if !slices.Equal(first, second) {
+		t.Fatalf("Expected identical offsets, got %v and %v", first, second)
+	}
+	if other := offsets(7); slices.Equal(first, other) {
+		t.Fatalf("Expected different seeds to produce different offsets, got %v", other)
+	}
+}
+
+// Test a task with manual advance waits for Advance after every run
+func TestWithManualAdvance(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 10)
+	h, err := s.ScheduleHandle("@every 1s", func(event Event) error {
+		events <- event
+		return nil
+	}, WithManualAdvance())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	expectNone := func() {
+		t.Helper()
+		time.Sleep(20 * time.Millisecond)
+		if n := len(events); n != 0 {
+			t.Fatalf("Expected the task to be held, got %d runs", n)
+		}
+	}
+	expect := func(want time.Time) {
+		t.Helper()
+		select {
+		case event := <-events:
+			if !event.Scheduled.Equal(want) {
+				t.Fatalf("Expected occurrence %v, got %v", want, event.Scheduled)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected occurrence %v to fire", want)
+		}
+	}
+
+	// The first occurrence fires as usual.
+	clock.Advance(time.Second)
+	expect(start.Add(time.Second))
+
+	// The next one is held until advanced.
+	clock.Advance(time.Second)
+	expectNone()
+	h.Advance()
+	expect(start.Add(2 * time.Second))
+
+	// Occurrences passed while held are skipped.
+	clock.Advance(5 * time.Second)
+	expectNone()
+	h.Advance()
+	expect(start.Add(7 * time.Second))
+
+	// Advancing before the occurrence is due waits for it.
+	h.Advance()
+	expectNone()
+	clock.Advance(time.Second)
+	expect(start.Add(8 * time.Second))
+	clock.Advance(time.Second)
+	expectNone()
+}
+
+// Test errors stop the task only until its first success
+func TestWithStopUntilFirstSuccess(t *testing.T) {
+	errFail := errors.New("misconfigured")
+
+	// An error on the first run stops the task.
+	s := New(time.Now(), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	h, err := s.ScheduleHandle("@every 10ms", func(event Event) error {
+		return errFail
+	}, WithStopUntilFirstSuccess())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reason, err := h.Wait(); reason != HandlerFailed || !errors.Is(err, errFail) {
+		t.Fatalf("Expected the first error to stop the task, got %v: %v", reason, err)
+	}
+
+	// A success followed by errors keeps the task running.
+	var runs atomic.Int32
+	h, err = s.ScheduleHandle("@every 10ms", func(event Event) error {
+		if runs.Add(1) == 1 {
+			return nil
+		}
+		return errFail
+	}, WithStopUntilFirstSuccess())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	waitRuns(t, h, 4)
+	if h.Reason() != Running {
+		t.Fatalf("Expected the task to keep running, got %v", h.Reason())
+	}
+	if stats := h.Stats(); stats.Errors < 3 {
+		t.Fatalf("Expected errors to be counted, got %d", stats.Errors)
+	}
+}
+
+// Test errors that keep the task running are reported at most once per window
+func TestWithErrorRateLimit(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	var buf bytes.Buffer
+	s := New(start, WithClock(clock), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	h, err := s.ScheduleHandle("@every 1s", func(event Event) error {
+		if event.Scheduled.Equal(start.Add(time.Second)) {
+			return nil
+		}
+		return errors.New("flaky")
+	}, WithStopUntilFirstSuccess(), WithErrorRateLimit(5*time.Second))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	// One success, then an error every second for 11 seconds.
+	for i := 1; i <= 12; i++ {
+		waitArmed(t, clock, start.Add(time.Duration(i)*time.Second))
+		clock.Advance(time.Second)
+	}
+	waitArmed(t, clock, start.Add(13*time.Second))
+
+	if stats := h.Stats(); stats.Errors != 11 {
+		t.Fatalf("Expected 11 errors, got %d", stats.Errors)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 reports, got %d:\n%s", len(lines), buf.String())
+	}
+	if strings.Contains(lines[0], "suppressed") {
+		t.Fatalf("Expected the first report to suppress nothing, got %s", lines[0])
+	}
+	for _, line := range lines[1:] {
+		if !strings.Contains(line, "suppressed=4") {
+			t.Fatalf("Expected 4 suppressed errors, got %s", line)
+		}
+	}
+}
+
+// Test errors of triggered runs are rate limited like those of scheduled runs
+func TestWithErrorRateLimitTriggered(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	s := New(start, WithClock(NewFakeClock(start)), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	runs := make(chan struct{})
+	var n int
+	h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+		runs <- struct{}{}
+		if n++; n == 1 {
+			return nil
+		}
+		return errors.New("flaky")
+	}, WithStopUntilFirstSuccess(), WithErrorRateLimit(time.Minute))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// One success, then three errors within the minute.
+	for range 4 {
+		h.Trigger()
+		<-runs
+	}
+	h.Shutdown()
+
+	if stats := h.Stats(); stats.Errors != 3 {
+		t.Fatalf("Expected 3 errors, got %d", stats.Errors)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 1 {
+		t.Fatalf("Expected 1 report, got %d:\n%s", len(lines), buf.String())
+	}
+}
+
+// Test random runs within the interval fire exactly once per bucket
+func TestWithRandomWithinInterval(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock), WithRand(rand.New(rand.NewPCG(1, 2))))
+
+	events := make(chan Event)
+	h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+		events <- event
+		return nil
+	}, WithRandomWithinInterval())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	// deadline waits for the task's timer to be armed and returns when it expires.
+	deadline := func() time.Time {
+		for {
+			clock.mu.Lock()
+			for _, timer := range clock.timers {
+				if timer.active {
+					d := timer.deadline
+					clock.mu.Unlock()
+					return d
+				}
+			}
+			clock.mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	const buckets = 200
+	var offsets []time.Duration
+	for i := 1; i <= buckets; i++ {
+		bucket := start.Add(time.Duration(i) * time.Hour)
+
+		due := deadline()
+		if due.Before(bucket) || !due.Before(bucket.Add(time.Hour)) {
+			t.Fatalf("Expected run %d within [%v, %v), got %v", i, bucket, bucket.Add(time.Hour), due)
+		}
+
+		clock.Advance(due.Sub(clock.Now()))
+		event := <-events
+		if !event.Scheduled.Equal(bucket) {
+			t.Fatalf("Expected run %d in bucket %v, got %v", i, bucket, event.Scheduled)
+		}
+		offsets = append(offsets, event.Time.Sub(bucket))
+	}
+
+	// No second run happens within the last bucket.
+	if due := deadline(); due.Before(start.Add((buckets + 1) * time.Hour)) {
+		t.Fatalf("Expected the next run in the following bucket, got %v", due)
+	}
+
+	// The offsets spread across the buckets rather than sticking to their start.
+	var early, late int
+	for _, offset := range offsets {
+		if offset < 30*time.Minute {
+			early++
+		} else {
+			late++
+		}
+	}
+	if early < buckets/4 || late < buckets/4 {
+		t.Fatalf("Expected offsets spread across the bucket, got %d early and %d late", early, late)
+	}
+}
+
+// Test a burst of triggers results in a single run after the quiet period
+func TestWithDebounce(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 10)
+	h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+		events <- event
+		return nil
+	}, WithDebounce(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	// Each trigger comes before the previous one's quiet period ends.
+	for range 5 {
+		h.Trigger()
+		waitArmed(t, clock, clock.Now().Add(100*time.Millisecond))
+		clock.Advance(50 * time.Millisecond)
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no run during the burst, got one at %v", event.Time)
+	default:
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	if event := <-events; !event.Time.Equal(start.Add(300 * time.Millisecond)) {
+		t.Fatalf("Expected a run after the quiet period at 300ms, got %v", event.Time)
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case event := <-events:
+		t.Fatalf("Expected a single run, got another at %v", event.Time)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Test a task whose active window allows none of its occurrences stops as exhausted
+func TestWithActiveWindowNeverAllows(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	s := New(start, WithClock(NewFakeClock(start)), WithLocation(time.UTC))
+
+	h, err := s.ScheduleHandle("@daily", func(Event) error { return nil },
+		WithActiveWindow(9*time.Hour, 17*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if reason, _ := h.Wait(); reason != Exhausted {
+		t.Fatalf("Expected reason %v, got %v", Exhausted, reason)
+	}
+}
+
+// Test a weekend task with business days only stops as exhausted
+func TestWithBusinessDaysOnWeekends(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	s := New(start, WithClock(NewFakeClock(start)), WithLocation(time.UTC))
+
+	h, err := s.ScheduleHandle("@weekends", func(Event) error { return nil }, WithBusinessDays())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if reason, _ := h.Wait(); reason != Exhausted {
+		t.Fatalf("Expected reason %v, got %v", Exhausted, reason)
+	}
+}
+
+// Test an active window re-arms every day, whenever the task is scheduled
+func TestWithActiveWindowRearms(t *testing.T) {
+	day := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+
+	// The cadence counts from the opening, whatever the start time.
+	tests := []struct {
+		name        string
+		start, want time.Time
+	}{
+		{"before window", day.Add(8*time.Hour + 17*time.Minute), day.Add(9 * time.Hour)},
+		{"off cadence before window", day.Add(7*time.Hour + 57*time.Minute + 30*time.Second), day.Add(9 * time.Hour)},
+		{"just before window", day.Add(8*time.Hour + 57*time.Minute + 30*time.Second), day.Add(9 * time.Hour)},
+		{"in window", day.Add(9*time.Hour + 17*time.Minute), day.Add(9*time.Hour + 20*time.Minute)},
+		{"after window", day.Add(10*time.Hour + 30*time.Minute), day.Add(33 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.start, WithClock(NewFakeClock(tt.start)), WithLocation(time.UTC))
+			h, err := s.ScheduleHandle("@every 5m", func(Event) error { return nil },
+				WithActiveWindow(9*time.Hour, 10*time.Hour))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer h.Cancel()
+
+			if first := h.FirstRun(); !first.Equal(tt.want) {
+				t.Fatalf("Expected first run at %v, got %v", tt.want, first)
+			}
+			if second, want := h.schedule.NextOccurrence(tt.want), tt.want.Add(5*time.Minute); !second.Equal(want) {
+				t.Fatalf("Expected second run at %v, got %v", want, second)
+			}
+
+			// The last occurrence of a day is followed by the opening the next morning.
+			midnight := time.Date(tt.want.Year(), tt.want.Month(), tt.want.Day()+1, 0, 0, 0, 0, time.UTC)
+			times := h.schedule.Between(tt.want, midnight)
+			last := times[len(times)-1]
+			next := h.schedule.NextOccurrence(last)
+			if want := time.Date(last.Year(), last.Month(), last.Day()+1, 9, 0, 0, 0, time.UTC); !next.Equal(want) {
+				t.Fatalf("Expected the window to re-arm at %v, got %v", want, next)
+			}
+		})
+	}
+}
+
+// Test each job scheduled from data sees its own metadata
+func TestWithMetadata(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+	defer s.Stop()
+
+	configs := []struct {
+		name, endpoint string
+	}{
+		{"users", "https://example.com/users"},
+		{"orders", "https://example.com/orders"},
+		{"invoices", "https://example.com/invoices"},
+	}
+
+	type seen struct{ name, endpoint string }
+	events := make(chan seen, len(configs))
+	for _, config := range configs {
+		metadata := map[string]string{"endpoint": config.endpoint}
+		_, err := s.AddJob(config.name, "@every 1m", func(event Event) error {
+			events <- seen{event.Handle.Name(), event.Metadata()["endpoint"]}
+			return nil
+		}, WithMetadata(metadata))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// The job keeps its own copy.
+		metadata["endpoint"] = "changed"
+	}
+
+	clock.Advance(time.Minute)
+
+	got := make(map[string]string)
+	for range configs {
+		e := <-events
+		got[e.name] = e.endpoint
+	}
+	for _, config := range configs {
+		if got[config.name] != config.endpoint {
+			t.Fatalf("Expected job %q to see endpoint %q, got %q", config.name, config.endpoint, got[config.name])
+		}
+	}
+
+	if md := (Event{}).Metadata(); md != nil {
+		t.Fatalf("Expected no metadata without a handle, got %v", md)
+	}
+}
+
+// Test a task cancelled before its first tick runs once only with WithRunOnCancel
+func TestWithRunOnCancel(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	d := NewDispatcher(1)
+	defer d.Stop()
+
+	tests := []struct {
+		name string
+		opts []Option
+		run  bool
+		want int32
+	}{
+		{"default", nil, false, 0},
+		{"run", nil, true, 1},
+		{"run dispatched", []Option{WithDispatcher(d)}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(start)
+			s := New(start, append([]Option{WithClock(clock)}, tt.opts...)...)
+
+			var runs atomic.Int32
+			var final Event
+			h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+				runs.Add(1)
+				final = event
+				return nil
+			}, WithRunOnCancel(tt.run))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			h.Cancel()
+			h.Shutdown()
+			clock.Advance(2 * time.Hour)
+
+			if n := runs.Load(); n != tt.want {
+				t.Fatalf("Expected %d runs, got %d", tt.want, n)
+			}
+			if tt.want > 0 && !final.Scheduled.IsZero() {
+				t.Fatalf("Expected the final run to have no scheduled time, got %v", final.Scheduled)
+			}
+		})
+	}
+
+	// A task which already ran is not run again on cancellation.
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+	events := make(chan Event, 2)
+	h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+		events <- event
+		return nil
+	}, WithRunOnCancel(true))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.Advance(time.Hour)
+	<-events
+	h.Shutdown()
+	if len(events) != 0 {
+		t.Fatal("Expected no final run once the task ran")
+	}
+}
+
+// Test aligned harmonic intervals coincide, even across schedulers started apart
+func TestWithAlignFirstHarmonics(t *testing.T) {
+	start := time.Date(2024, 3, 8, 3, 17, 23, 0, time.UTC)
+
+	every := func(s *Scheduler, expr string) []time.Time {
+		h, err := s.ScheduleHandle(expr, func(Event) error { return nil }, WithAlignFirst())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		h.Cancel()
+		return h.schedule.Between(start, start.Add(2*time.Hour))
+	}
+
+	a := New(start, WithClock(NewFakeClock(start)))
+	later := start.Add(7*time.Minute + 41*time.Second)
+	b := New(later, WithClock(NewFakeClock(later)))
+
+	minutes := every(a, "@every 1m")
+	fives := every(b, "@every 5m")
+	quarters := every(a, "@every 15m")
+
+	contains := func(times []time.Time, t time.Time) bool {
+		for _, other := range times {
+			if other.Equal(t) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(quarters) == 0 {
+		t.Fatal("Expected 15m occurrences")
+	}
+	for _, q := range quarters {
+		if q.Minute()%15 != 0 || q.Second() != 0 {
+			t.Fatalf("Expected 15m occurrences on the quarter hour, got %v", q)
+		}
+		if !contains(fives, q) || !contains(minutes, q) {
+			t.Fatalf("Expected 15m occurrence %v to coincide with 5m and 1m ones", q)
+		}
+	}
+	for _, f := range fives {
+		if !contains(minutes, f) {
+			t.Fatalf("Expected 5m occurrence %v to coincide with a 1m one", f)
+		}
+	}
+}
+
+// Test a burst of triggers runs once right away and is suppressed during the cooldown
+func TestWithThrottle(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 10)
+	h, err := s.ScheduleHandle("@every 1h", func(event Event) error {
+		events <- event
+		return nil
+	}, WithThrottle(time.Second))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	h.Trigger()
+	if event := <-events; !event.Time.Equal(start) {
+		t.Fatalf("Expected an immediate run at %v, got %v", start, event.Time)
+	}
+
+	// The rest of the burst falls within the cooldown.
+	for range 5 {
+		clock.Advance(100 * time.Millisecond)
+		h.Trigger()
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("Expected triggers to be suppressed during the cooldown, got a run at %v", event.Time)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Once the cooldown is over, a trigger runs right away again.
+	clock.Advance(500 * time.Millisecond)
+	h.Trigger()
+	if event := <-events; !event.Time.Equal(start.Add(time.Second)) {
+		t.Fatalf("Expected a run after the cooldown at %v, got %v", start.Add(time.Second), event.Time)
+	}
+}
+
+// Test heartbeats fire between sparse occurrences and stop with the task
+func TestWithHeartbeat(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	beats := make(chan time.Time, 1)
+	h, err := s.ScheduleHandle("@yearly", func(Event) error {
+		t.Error("Expected no run")
+		return nil
+	}, WithHeartbeat(time.Hour, func(now time.Time) { beats <- now }))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		at := start.Add(time.Duration(i) * time.Hour)
+		waitArmed(t, clock, at)
+		clock.Advance(time.Hour)
+		if beat := <-beats; !beat.Equal(at) {
+			t.Fatalf("Expected heartbeat %d at %v, got %v", i, at, beat)
+		}
+	}
+
+	// Once stopped, the task and its heartbeat stop their timers.
+	h.Cancel()
+	for {
+		clock.mu.Lock()
+		waiters := clock.waiters()
+		clock.mu.Unlock()
+		if waiters == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Hour)
+	select {
+	case beat := <-beats:
+		t.Fatalf("Expected no heartbeat after the task stopped, got %v", beat)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// Test contradicting options are rejected before anything is scheduled
+func TestIncompatibleOptions(t *testing.T) {
+	s := New(time.Now())
+	handler := func(Event) error { return nil }
+
+	tests := [][]JobOption{
+		{WithFixedDelay(time.Second), WithAlignFirst()},
+		{WithFixedDelay(time.Second), WithAsyncDispatch()},
+		{WithRandomWithinInterval(), WithFixedDelay(time.Second)},
+		{WithInitialDelay(time.Second), WithAlignFirst()},
+		{WithJitter(time.Second), WithRandomWithinInterval()},
+	}
+	for i, opts := range tests {
+		if _, err := s.ScheduleHandle("@every 1m", handler, opts...); !errors.Is(err, ErrIncompatibleOptions) {
+			t.Fatalf("Combination %d: expected %v, got %v", i, ErrIncompatibleOptions, err)
+		}
+	}
+
+	if _, err := s.AddJob("job", "@every 1m", handler, WithFixedDelay(time.Second), WithAlignFirst()); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("Expected %v, got %v", ErrIncompatibleOptions, err)
+	}
+	if _, _, err := s.ScheduleAll([]Spec{
+		{Expr: "@every 1m", Handler: handler},
+		{Expr: "@every 1m", Handler: handler, Options: []JobOption{WithJitter(time.Second), WithRandomWithinInterval()}},
+	}); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("Expected %v, got %v", ErrIncompatibleOptions, err)
+	}
+	if n := len(s.tasks()); n != 0 {
+		t.Fatalf("Expected nothing to be scheduled, got %d tasks", n)
+	}
+
+	// Compatible options are accepted.
+	h, err := s.ScheduleHandle("@every 1m", handler, WithAlignFirst(), WithJitter(time.Second))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	h.Cancel()
+
+	d := NewDispatcher(1)
+	defer d.Stop()
+	if _, err := NewValidated(time.Now(), WithDispatcher(d), WithClock(NewFakeClock(time.Now()))); !errors.Is(err, ErrIncompatibleOptions) {
+		t.Fatalf("Expected %v, got %v", ErrIncompatibleOptions, err)
+	}
+	if _, err := NewValidated(time.Now(), WithDispatcher(d)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrJobExists is returned when adding a job under a name that is already registered.
+	ErrJobExists = errors.New("job already exists")
+
+	// ErrJobNotFound is returned when no job is registered under the given name.
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// registry holds the running tasks and named jobs of a Scheduler.
+type registry struct {
+	mu    sync.Mutex
+	tasks map[*Handle]struct{}
+	jobs  map[string]*Handle
+}
+
+// Stop cancels every task started by the scheduler. The scheduler can still be
+// used to schedule new tasks afterwards.
+func (s *Scheduler) Stop() {
+	// Cancel outside the lock, since stopping a task removes it from the registry.
+	for _, h := range s.tasks() {
+		h.Cancel()
+	}
+}
+
+// Shutdown cancels every task started by the scheduler like Stop, then blocks until
+// their goroutines have exited and no run is in progress. It must not be called from
+// a handler of the scheduler.
+func (s *Scheduler) Shutdown() {
+	tasks := s.tasks()
+	for _, h := range tasks {
+		h.Cancel()
+	}
+	for _, h := range tasks {
+		h.Shutdown()
+	}
+}
+
+// RunUntil starts the scheduler, releasing tasks held by WithStartBarrier, and blocks
+// until ctx is done. It then shuts the scheduler down like Shutdown before returning,
+// which makes it the main loop of a simple daemon. Tasks keep running until then.
+func (s *Scheduler) RunUntil(ctx context.Context) {
+	s.Start()
+	<-ctx.Done()
+	s.Shutdown()
+}
+
+// CancelByTag cancels every running task tagged with tag and returns how many were cancelled.
+func (s *Scheduler) CancelByTag(tag string) int {
+	var n int
+	for _, h := range s.tasks() {
+		if slices.Contains(h.tags, tag) {
+			h.Cancel()
+			n++
+		}
+	}
+	return n
+}
+
+// JobInfo describes a running task to the predicate of CancelWhere.
+type JobInfo struct {
+	// Name is the name of a named job, or empty.
+	Name string
+	// Tags are the tags the task was scheduled with.
+	Tags []string
+	// Expr is the expression the task was scheduled with, or empty.
+	Expr string
+	// Stats are the run statistics of the task.
+	Stats Stats
+}
+
+// CancelWhere cancels every running task for which pred returns true and returns how
+// many were cancelled.
+func (s *Scheduler) CancelWhere(pred func(JobInfo) bool) int {
+	var n int
+	for _, h := range s.tasks() {
+		info := JobInfo{Name: h.name, Tags: h.tags, Expr: h.Expr(), Stats: h.Stats()}
+		if pred(info) {
+			h.Cancel()
+			n++
+		}
+	}
+	return n
+}
+
+// tasks returns the running tasks of the scheduler.
+func (s *Scheduler) tasks() []*Handle {
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+
+	tasks := make([]*Handle, 0, len(s.registry.tasks))
+	for h := range s.registry.tasks {
+		tasks = append(tasks, h)
+	}
+	return tasks
+}
+
+// AddJob sets up a scheduled task like ScheduleHandle and registers it under the given name.
+// The job is removed from the registry once it stops. With a LastRunStore, occurrences
+// missed since the job's last recorded run are run first.
+// By default, adding a job under a name that is already registered fails with
+// ErrJobExists; with WithReplaceExisting, the new job takes over the name and the
+// existing one is cancelled.
+func (s *Scheduler) AddJob(name, expr string, handler Handler, opts ...JobOption) (*Handle, error) {
+	ce, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := s.newHandle(ce, handler, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.name = name
+
+	// Catch up on the occurrences missed since the last recorded run.
+	if s.lastRuns != nil {
+		last, err := s.lastRuns.Get(name)
+		if err != nil {
+			s.logger.Warn("scheduler: failed to load last run", "job", name, "error", err)
+		}
+		h.missed = h.missedOccurrences(last, s.clock.Now())
+	}
+
+	s.registry.mu.Lock()
+	existing, ok := s.registry.jobs[name]
+	if ok && !h.replace {
+		s.registry.mu.Unlock()
+		return nil, ErrJobExists
+	}
+	if s.registry.jobs == nil {
+		s.registry.jobs = make(map[string]*Handle)
+	}
+	duplicates := s.duplicateHandlers(h)
+	s.registry.jobs[name] = h
+	s.registry.mu.Unlock()
+
+	for _, other := range duplicates {
+		s.logger.Warn("scheduler: duplicate handler registered", "job", name, "duplicate", other)
+	}
+
+	// The name already refers to the new job, so the replaced one leaves it alone when it stops.
+	if existing != nil {
+		existing.Cancel()
+	}
+
+	if s.onJobAdded != nil {
+		s.onJobAdded(name)
+	}
+
+	h.start()
+
+	return h, nil
+}
+
+// Job returns the job registered under the given name.
+func (s *Scheduler) Job(name string) (*Handle, bool) {
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+
+	h, ok := s.registry.jobs[name]
+	return h, ok
+}
+
+// OnComplete chains handler to the named job: it runs after every successful run of the
+// job, on the same goroutine, and receives the event of that run. A handler returning
+// ErrStop is unchained, while other errors are logged without affecting the job.
+// The returned function unchains the handler.
+func (s *Scheduler) OnComplete(jobName string, handler Handler) (func(), error) {
+	h, ok := s.Job(jobName)
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	d := &dependent{handler: handler}
+	h.addDependent(d)
+	return func() { h.removeDependent(d) }, nil
+}
+
+// Disable keeps the named job registered and tracking its cadence, but stops it
+// from firing until it is enabled again.
+func (s *Scheduler) Disable(name string) error {
+	return s.setEnabled(name, false)
+}
+
+// Enable lets a disabled named job fire again.
+func (s *Scheduler) Enable(name string) error {
+	return s.setEnabled(name, true)
+}
+
+// setEnabled toggles the enabled state of the named job.
+func (s *Scheduler) setEnabled(name string, enabled bool) error {
+	h, ok := s.Job(name)
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	h.disabled.Store(!enabled)
+	return nil
+}
+
+// duplicateHandlers returns the names of the registered jobs sharing the handler of h,
+// if duplicate checks are enabled. The registry lock must be held.
+func (s *Scheduler) duplicateHandlers(h *Handle) (names []string) {
+	if !s.checkDuplicates || h.handler == nil {
+		return nil
+	}
+
+	ptr := reflect.ValueOf(h.handler).Pointer()
+	for name, other := range s.registry.jobs {
+		// A job being replaced under the same name is no duplicate.
+		if name == h.name && h.replace {
+			continue
+		}
+		if other.handler != nil && reflect.ValueOf(other.handler).Pointer() == ptr {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// track registers h as a running task.
+func (s *Scheduler) track(h *Handle) {
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+
+	if s.registry.tasks == nil {
+		s.registry.tasks = make(map[*Handle]struct{})
+	}
+	s.registry.tasks[h] = struct{}{}
+}
+
+// untrack removes the stopped task h from the registry.
+func (s *Scheduler) untrack(h *Handle) {
+	s.registry.mu.Lock()
+	delete(s.registry.tasks, h)
+	removed := h.name != "" && s.registry.jobs[h.name] == h
+	if removed {
+		delete(s.registry.jobs, h.name)
+	}
+	s.registry.mu.Unlock()
+
+	// The callback runs without the lock, so it may use the scheduler.
+	if removed && s.onJobRemoved != nil {
+		s.onJobRemoved(h.name, h.Reason())
+	}
+}
@@ -0,0 +1,506 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test adding named jobs
+func TestAddJob(t *testing.T) {
+	s := New(time.Now())
+	handler := func(event Event) error { return nil }
+
+	h, err := s.AddJob("report", "@every 1s", handler)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := s.AddJob("report", "@every 1s", handler); !errors.Is(err, ErrJobExists) {
+		t.Fatalf("Expected ErrJobExists, got %v", err)
+	}
+
+	if got, ok := s.Job("report"); !ok || got != h {
+		t.Fatal("Expected job to be registered")
+	}
+
+	h.Cancel()
+	if _, ok := s.Job("report"); ok {
+		t.Fatal("Expected cancelled job to be removed")
+	}
+}
+
+// Test replacing a named job instead of failing
+func TestAddJobReplaceExisting(t *testing.T) {
+	s := New(time.Now())
+	defer s.Stop()
+	handler := func(event Event) error { return nil }
+
+	old, err := s.AddJob("report", "@every 1h", handler)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h, err := s.AddJob("report", "@every 2h", handler, WithReplaceExisting())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if reason, _ := old.Wait(); reason != Cancelled {
+		t.Fatalf("Expected the replaced job to be cancelled, got %v", reason)
+	}
+	if got, ok := s.Job("report"); !ok || got != h {
+		t.Fatal("Expected the new job to be registered under the name")
+	}
+	if h.Expr() != "@every 2h" || h.Reason() != Running {
+		t.Fatalf("Expected the new job to be running %q, got %q (%v)", "@every 2h", h.Expr(), h.Reason())
+	}
+
+	// Without the option, the name stays taken.
+	if _, err := s.AddJob("report", "@every 3h", handler); !errors.Is(err, ErrJobExists) {
+		t.Fatalf("Expected ErrJobExists, got %v", err)
+	}
+	if got, _ := s.Job("report"); got != h {
+		t.Fatal("Expected the failed add to leave the job in place")
+	}
+}
+
+// Test disabling and enabling a named job across ticks
+func TestDisableEnableJob(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event)
+	h, err := s.AddJob("poll", "@every 10s", func(event Event) error {
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	if err := s.Disable("poll"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Disabled ticks are skipped but keep the cadence.
+	for i := 0; i < 2; i++ {
+		clock.Advance(10 * time.Second)
+		select {
+		case event := <-events:
+			t.Fatalf("Expected disabled job not to fire, got %v", event.Time)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	if err := s.Enable("poll"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	clock.Advance(10 * time.Second)
+	select {
+	case event := <-events:
+		if want := start.Add(30 * time.Second); !event.Time.Equal(want) {
+			t.Fatalf("Expected event at %v, got %v", want, event.Time)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected enabled job to fire")
+	}
+
+	if err := s.Enable("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("Expected ErrJobNotFound, got %v", err)
+	}
+}
+
+// Test Stop cancels every task of the scheduler
+func TestSchedulerStop(t *testing.T) {
+	s := New(time.Now())
+	handler := func(event Event) error { return nil }
+
+	h1, _ := s.ScheduleHandle("@every 1s", handler)
+	h2, _ := s.AddJob("named", "@every 1s", handler)
+
+	s.Stop()
+
+	for _, h := range []*Handle{h1, h2} {
+		select {
+		case <-h.Done():
+		default:
+			t.Fatal("Expected task to be stopped")
+		}
+	}
+
+	if _, ok := s.Job("named"); ok {
+		t.Fatal("Expected stopped job to be removed")
+	}
+}
+
+// Test cancelling the scheduler's context stops every task without leaking goroutines
+func TestNewWithContext(t *testing.T) {
+	base := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewWithContext(ctx, time.Now())
+	handler := func(event Event) error { return nil }
+
+	var handles []*Handle
+	for i := 0; i < 5; i++ {
+		h, err := s.ScheduleHandle("@every 10ms", handler)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		handles = append(handles, h)
+	}
+
+	cancel()
+	for _, h := range handles {
+		select {
+		case <-h.Done():
+		case <-time.After(time.Second):
+			t.Fatal("Expected task to stop on context cancellation")
+		}
+	}
+
+	// Tasks scheduled after cancellation never run.
+	late, _ := s.ScheduleHandle("@every 10ms", handler)
+	select {
+	case <-late.Done():
+	default:
+		t.Fatal("Expected late task to be stopped")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > base && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > base {
+		t.Fatalf("Expected goroutines to exit, %d remain above baseline", n-base)
+	}
+}
+
+// Test a warning is logged when a handler is registered under two names
+func TestDuplicateHandlerWarning(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	s := New(time.Now(), WithLogger(logger), WithDuplicateHandlerCheck())
+	defer s.Stop()
+
+	handler := func(event Event) error { return nil }
+	other := func(event Event) error { return errors.New("other") }
+
+	if _, err := s.AddJob("a", "@every 1h", handler); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.AddJob("b", "@every 1h", other); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no warning for distinct handlers, got %q", buf.String())
+	}
+
+	if _, err := s.AddJob("c", "@every 1h", handler); err != nil {
+		t.Fatalf("Expected duplicate to be registered, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "duplicate handler") || !strings.Contains(buf.String(), "duplicate=a") {
+		t.Fatalf("Expected duplicate warning, got %q", buf.String())
+	}
+}
+
+// Test CancelByTag cancels only the tasks carrying the tag
+func TestCancelByTag(t *testing.T) {
+	s := New(time.Now())
+	defer s.Stop()
+
+	handler := func(event Event) error { return nil }
+
+	cache1, err := s.AddJob("cache-users", "@every 1h", handler, WithTags("cache"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cache2, err := s.ScheduleHandle("@every 1h", handler, WithTags("cache", "users"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	billing, err := s.AddJob("invoices", "@every 1h", handler, WithTags("billing"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if n := s.CancelByTag("cache"); n != 2 {
+		t.Fatalf("Expected 2 cancelled tasks, got %d", n)
+	}
+
+	for _, h := range []*Handle{cache1, cache2} {
+		if h.Reason() != Cancelled {
+			t.Fatalf("Expected tagged task to be cancelled, got %v", h.Reason())
+		}
+	}
+	if billing.Reason() != Running {
+		t.Fatalf("Expected untagged task to keep running, got %v", billing.Reason())
+	}
+	if _, ok := s.Job("cache-users"); ok {
+		t.Fatal("Expected cancelled job to be removed from the registry")
+	}
+
+	if n := s.CancelByTag("cache"); n != 0 {
+		t.Fatalf("Expected no tasks left to cancel, got %d", n)
+	}
+}
+
+// Test CancelWhere cancels the tasks matching the predicate
+func TestCancelWhere(t *testing.T) {
+	s := New(time.Now())
+	defer s.Stop()
+
+	handler := func(event Event) error { return nil }
+
+	ran, err := s.AddJob("ran", "@every 1h", handler, WithTags("cache"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ran.Trigger()
+	waitRuns(t, ran, 1)
+
+	idle1, err := s.AddJob("idle", "@every 1h", handler)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	idle2, err := s.ScheduleHandle("@every 2h", handler, WithTags("cache"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var seen []JobInfo
+	n := s.CancelWhere(func(info JobInfo) bool {
+		seen = append(seen, info)
+		return info.Stats.Runs == 0
+	})
+	if n != 2 {
+		t.Fatalf("Expected 2 cancelled tasks, got %d", n)
+	}
+	if idle1.Reason() != Cancelled || idle2.Reason() != Cancelled || ran.Reason() != Running {
+		t.Fatalf("Expected only idle tasks to be cancelled, got %v, %v and %v", idle1.Reason(), idle2.Reason(), ran.Reason())
+	}
+
+	// The predicate sees every task's details.
+	slices.SortFunc(seen, func(a, b JobInfo) int { return strings.Compare(a.Name, b.Name) })
+	if len(seen) != 3 || seen[0].Name != "" || seen[0].Expr != "@every 2h" || !slices.Equal(seen[0].Tags, []string{"cache"}) ||
+		seen[2].Name != "ran" || seen[2].Stats.Runs != 1 {
+		t.Fatalf("Unexpected job infos %+v", seen)
+	}
+}
+
+// Test RunUntil keeps every job running until the context is cancelled
+func TestRunUntil(t *testing.T) {
+	s := New(time.Now(), WithStartBarrier())
+
+	var runs [3]atomic.Int32
+	started := make(chan struct{}, len(runs))
+	var inFlight atomic.Bool
+	for i := range runs {
+		_, err := s.Schedule("@every 10ms", func(event Event) error {
+			inFlight.Store(true)
+			defer inFlight.Store(false)
+			if runs[i].Add(1) == 2 {
+				started <- struct{}{}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan struct{})
+	go func() {
+		s.RunUntil(ctx)
+		close(returned)
+	}()
+
+	// Every job runs repeatedly while RunUntil blocks.
+	for range runs {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("Expected every job to run while the scheduler runs")
+		}
+	}
+	select {
+	case <-returned:
+		t.Fatal("Expected RunUntil to block until the context is cancelled")
+	default:
+	}
+
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("Expected RunUntil to return once the context is cancelled")
+	}
+
+	if len(s.tasks()) != 0 || inFlight.Load() {
+		t.Fatal("Expected every job to be stopped once RunUntil returns")
+	}
+}
+
+// Test callbacks observe jobs being added and removed
+func TestJobAddedRemovedCallbacks(t *testing.T) {
+	type change struct {
+		name   string
+		added  bool
+		reason StopReason
+	}
+	changes := make(chan change, 10)
+
+	var s *Scheduler
+	s = New(time.Now(),
+		WithOnJobAdded(func(name string) {
+			// The registry is not locked during the callback.
+			if _, ok := s.Job(name); !ok {
+				t.Errorf("Expected job %q to be registered when reported", name)
+			}
+			changes <- change{name: name, added: true}
+		}),
+		WithOnJobRemoved(func(name string, reason StopReason) {
+			if _, ok := s.Job(name); ok {
+				t.Errorf("Expected job %q to be unregistered when reported", name)
+			}
+			changes <- change{name: name, reason: reason}
+		}),
+	)
+
+	report, err := s.AddJob("report", "@every 1h", func(Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	failing, err := s.AddJob("failing", "@every 10ms", func(Event) error { return errors.New("boom") })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.ScheduleHandle("@every 1h", func(Event) error { return nil }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	<-failing.Done()
+	report.Cancel()
+	s.Stop()
+
+	want := []change{
+		{name: "report", added: true},
+		{name: "failing", added: true},
+		{name: "failing", reason: HandlerFailed},
+		{name: "report", reason: Cancelled},
+	}
+	for _, w := range want {
+		select {
+		case got := <-changes:
+			if got != w {
+				t.Fatalf("Expected %+v, got %+v", w, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %+v", w)
+		}
+	}
+	if len(changes) != 0 {
+		t.Fatalf("Expected only named jobs to be reported, got %+v", <-changes)
+	}
+}
+
+// Test a handler chained to a job runs after each of its successful runs
+func TestOnComplete(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	if _, err := s.OnComplete("missing", func(Event) error { return nil }); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("Expected %v, got %v", ErrJobNotFound, err)
+	}
+
+	var order []string
+	a, err := s.AddJob("a", "@every 1s", func(Event) error {
+		order = append(order, "a")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer a.Cancel()
+
+	completed := make(chan Event, 2)
+	unchain, err := s.OnComplete("a", func(event Event) error {
+		order = append(order, "b")
+		completed <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		scheduled := start.Add(time.Duration(i) * time.Second)
+		waitArmed(t, clock, scheduled)
+		clock.Advance(time.Second)
+		if event := <-completed; !event.Scheduled.Equal(scheduled) || event.Handle != a {
+			t.Fatalf("Expected the run of a at %v, got %+v", scheduled, event)
+		}
+	}
+
+	// Once unchained, b no longer runs.
+	unchain()
+	waitArmed(t, clock, start.Add(3*time.Second))
+	clock.Advance(time.Second)
+	waitArmed(t, clock, start.Add(4*time.Second))
+
+	if want := []string{"a", "b", "a", "b", "a"}; !slices.Equal(order, want) {
+		t.Fatalf("Expected runs %v, got %v", want, order)
+	}
+}
+
+// Test a slow chained handler delays the next run like a slow handler
+func TestOnCompleteSlow(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 2)
+	a, err := s.AddJob("a", "@every 1s", func(event Event) error {
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer a.Cancel()
+
+	slow := true
+	if _, err := s.OnComplete("a", func(Event) error {
+		if slow {
+			slow = false
+			clock.Advance(2500 * time.Millisecond)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	waitArmed(t, clock, start.Add(time.Second))
+	clock.Advance(time.Second)
+	<-events
+
+	// The occurrences at 2s and 3s passed while the chained handler ran.
+	waitArmed(t, clock, start.Add(4*time.Second))
+	clock.Advance(500 * time.Millisecond)
+	if event := <-events; !event.Scheduled.Equal(start.Add(4*time.Second)) || event.Missed != 2 {
+		t.Fatalf("Expected the run at 4s after 2 missed, got %v after %d", event.Scheduled, event.Missed)
+	}
+}
@@ -0,0 +1,627 @@
+package scheduler
+
+import (
+	"math/bits"
+	"time"
+)
+
+// Kind is the form of expression a Schedule was created from.
+type Kind int
+
+const (
+	// KindUnknown is the kind of schedules created without an expression.
+	KindUnknown Kind = iota
+	// KindPredefined is the kind of predefined aliases such as @daily.
+	KindPredefined
+	// KindEvery is the kind of @every intervals.
+	KindEvery
+	// KindSource is the kind of schedules driven by an OccurrenceSource.
+	KindSource
+	// KindCron is the kind of cron expressions.
+	KindCron
+)
+
+// String returns the name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindPredefined:
+		return "predefined"
+	case KindEvery:
+		return "every"
+	case KindSource:
+		return "source"
+	case KindCron:
+		return "cron"
+	}
+	return "unknown"
+}
+
+// Schedule defines a recurring frequency for event execution.
+// When Source is set, it drives the occurrences instead of Frequency.
+type Schedule struct {
+	// Expr is the expression the schedule was parsed from, if any.
+	Expr string
+
+	Kind      Kind
+	Frequency time.Duration
+	Source    OccurrenceSource
+
+	// Intervals, when set, replaces Frequency with a cycle of intervals: the
+	// occurrences are spaced by each interval in turn, starting over after the last.
+	// The cycle is aligned to the anchor.
+	Intervals []time.Duration
+
+	// Anchor is the instant duration schedules are aligned to.
+	// If it is zero, the Unix epoch is used.
+	Anchor time.Time
+
+	// Location is the time zone calendar occurrences are computed in.
+	// If it is nil, the location of the previous occurrence is used.
+	Location *time.Location
+
+	// days restricts a calendar schedule to midnight on the given weekdays.
+	days weekdaySet
+
+	// period fires a calendar schedule at the start of every period instead.
+	period calendarPeriod
+
+	// times, when set, fires a calendar schedule at each of these sorted offsets from
+	// midnight instead, on every day or on the days in days.
+	times []time.Duration
+
+	// cron drives the occurrences of cron schedules.
+	cron *cronSpec
+
+	// anchored aligns an interval of whole days to the wall clock time at, given as
+	// an offset from midnight, rather than to the anchor instant.
+	anchored bool
+	at       time.Duration
+
+	// filters skip occurrences they do not allow.
+	filters []filter
+}
+
+// calendarPeriod is the wall clock period of a predefined alias such as @daily.
+type calendarPeriod int
+
+const (
+	hourly calendarPeriod = iota + 1
+	daily
+	weekly
+	monthly
+	yearly
+)
+
+// after returns the start of the period following the one containing t, in t's
+// location. Days start at midnight and weeks on Sunday, so they keep their time of
+// day across DST transitions, while hours are always 60 minutes apart.
+func (p calendarPeriod) after(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch p {
+	case hourly:
+		// Truncate in the current UTC offset, so zones offset by a fraction of an
+		// hour fire on the hour too.
+		_, offset := t.Zone()
+		shift := time.Duration(offset) * time.Second
+		return t.Add(shift).Truncate(time.Hour).Add(time.Hour - shift)
+	case daily:
+		return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+	case weekly:
+		return time.Date(y, m, d+7-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+	case monthly:
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+	case yearly:
+		return time.Date(y+1, time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// perDay returns how many periods start in a day, averaged over the Gregorian calendar.
+func (p calendarPeriod) perDay() float64 {
+	const daysPerYear = 365.2425
+	switch p {
+	case hourly:
+		return 24
+	case daily:
+		return 1
+	case weekly:
+		return 1.0 / 7
+	case monthly:
+		return 12 / daysPerYear
+	case yearly:
+		return 1 / daysPerYear
+	}
+	return 0
+}
+
+// filter restricts the occurrences of a Schedule.
+type filter interface {
+	// allows reports whether an occurrence at t may fire.
+	allows(t time.Time) bool
+	// resume returns the earliest instant after the disallowed t that may be allowed.
+	resume(t time.Time) time.Time
+}
+
+// dateFilter disallows occurrences on a set of calendar dates.
+type dateFilter map[date]struct{}
+
+// date is a calendar date.
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+// dateOf returns the calendar date of t in its location.
+func dateOf(t time.Time) date {
+	y, m, d := t.Date()
+	return date{y, m, d}
+}
+
+func (f dateFilter) allows(t time.Time) bool {
+	_, excluded := f[dateOf(t)]
+	return !excluded
+}
+
+func (f dateFilter) resume(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+}
+
+// windowFilter only allows occurrences whose time of day is within [start, end).
+// A window whose end is before its start wraps around midnight.
+type windowFilter struct {
+	start, end time.Duration
+}
+
+// timeOfDay returns the wall clock time of t as an offset from midnight.
+func timeOfDay(t time.Time) time.Duration {
+	h, m, s := t.Clock()
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(s)*time.Second + time.Duration(t.Nanosecond())
+}
+
+// atTimeOfDay returns the instant at the given wall clock offset on the day of t.
+func atTimeOfDay(t time.Time, offset time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, int(offset), t.Location())
+}
+
+func (f windowFilter) allows(t time.Time) bool {
+	offset := timeOfDay(t)
+	if f.start <= f.end {
+		return offset >= f.start && offset < f.end
+	}
+	return offset >= f.start || offset < f.end
+}
+
+func (f windowFilter) resume(t time.Time) time.Time {
+	// The window opens later today, or else tomorrow.
+	if timeOfDay(t) < f.start {
+		return atTimeOfDay(t, f.start)
+	}
+	return atTimeOfDay(t.AddDate(0, 0, 1), f.start)
+}
+
+// Window skips every occurrence whose wall clock time of day is outside [start, end),
+// both given as offsets from midnight. If end is before start, the window wraps
+// around midnight, e.g. from 22:00 until 06:00 the next morning. Duration schedules
+// are aligned to the opening of the window rather than to the anchor. A window that
+// allows none of the occurrences, such as 09:00-17:00 for @daily, leaves the schedule
+// without occurrences.
+func (s *Schedule) Window(start, end time.Duration) {
+	s.filters = append(s.filters, windowFilter{start, end})
+}
+
+// weekdayFilter only allows occurrences on the weekdays in the set.
+type weekdayFilter weekdaySet
+
+func (f weekdayFilter) allows(t time.Time) bool {
+	return weekdaySet(f).has(t.Weekday())
+}
+
+func (f weekdayFilter) resume(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+}
+
+// BusinessDays skips every occurrence falling on a Saturday or Sunday, or on one of the
+// calendar dates of the given holidays, so a daily schedule advances by business days.
+// A schedule firing only on weekends is left without occurrences.
+func (s *Schedule) BusinessDays(holidays ...time.Time) {
+	s.filters = append(s.filters, weekdayFilter(newWeekdaySet(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)))
+	if len(holidays) > 0 {
+		s.Exclude(holidays...)
+	}
+}
+
+// Exclude skips every occurrence falling on one of the calendar dates of the given
+// times. Dates are compared in the schedule's location when occurrences are computed.
+func (s *Schedule) Exclude(dates ...time.Time) {
+	f := make(dateFilter, len(dates))
+	for _, d := range dates {
+		f[dateOf(d)] = struct{}{}
+	}
+	s.filters = append(s.filters, f)
+}
+
+// weekdaySet is a bit set of weekdays, indexed by time.Weekday.
+type weekdaySet uint8
+
+// newWeekdaySet creates a weekdaySet containing the given days.
+func newWeekdaySet(days ...time.Weekday) (set weekdaySet) {
+	for _, d := range days {
+		set |= 1 << d
+	}
+	return
+}
+
+// has reports whether the set contains the given day.
+func (w weekdaySet) has(d time.Weekday) bool {
+	return w&(1<<d) != 0
+}
+
+// NextOccurrence calculates the next scheduled execution time based on the previous one.
+// It returns the zero time when the schedule has no further occurrences. Occurrences
+// always land on the schedule's boundaries, even if prev is off them, such as the actual
+// time a run was late: duration schedules align to the anchor and cron schedules to the
+// whole second.
+func (s *Schedule) NextOccurrence(prev time.Time) time.Time {
+	return s.NextOccurrenceIn(prev, s.location(prev))
+}
+
+// NextOccurrenceIn calculates the next scheduled execution time like NextOccurrence,
+// computing calendar occurrences in the given location.
+func (s *Schedule) NextOccurrenceIn(prev time.Time, loc *time.Location) time.Time {
+	return s.skipFiltered(s.next(prev.In(loc)))
+}
+
+// skipFiltered advances t to the first occurrence allowed by every filter.
+// It returns the zero time if the filters allow nothing within five years.
+func (s *Schedule) skipFiltered(t time.Time) time.Time {
+	limit := t.AddDate(5, 0, 0)
+	for !t.IsZero() {
+		if t.After(limit) {
+			return time.Time{}
+		}
+
+		allowed := true
+		for _, f := range s.filters {
+			if !f.allows(t) {
+				// Resume from the first occurrence the filter may allow. Duration schedules
+				// restart their cadence when a window opens, so it re-arms every day.
+				t = s.alignedAfter(f.resume(t).Add(-time.Nanosecond))
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			break
+		}
+	}
+	return t
+}
+
+// next returns the occurrence following prev, ignoring filters.
+func (s *Schedule) next(prev time.Time) (next time.Time) {
+	if s.Source != nil {
+		next, _ = s.Source.Next(prev)
+		return
+	}
+
+	if s.period != 0 {
+		next = s.period.after(prev)
+		return
+	}
+
+	if s.cron != nil {
+		next = s.cron.next(prev)
+		return
+	}
+
+	if s.anchored {
+		next = s.nextAnchored(prev)
+		return
+	}
+
+	if len(s.Intervals) > 0 {
+		next = s.nextInCycle(prev)
+		return
+	}
+
+	if len(s.times) > 0 {
+		next = s.nextTime(prev)
+		return
+	}
+
+	// Advance to the following midnight until it falls on a matching day.
+	if s.days != 0 {
+		next = time.Date(prev.Year(), prev.Month(), prev.Day()+1, 0, 0, 0, 0, prev.Location())
+		for !s.days.has(next.Weekday()) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return
+	}
+
+	// Align to the anchor rather than adding to prev, which may be off a boundary.
+	next = s.alignedAfter(prev)
+	return
+}
+
+// Matches reports whether t is a valid occurrence of the schedule.
+// Duration schedules match instants aligned to the anchor, while calendar schedules
+// and sources match their own occurrences, such as midnight on the matching days.
+func (s *Schedule) Matches(t time.Time) bool {
+	if !s.IsActive(t) {
+		return false
+	}
+
+	if !s.isDuration() {
+		return s.next(t.Add(-time.Nanosecond).In(s.location(t))).Equal(t)
+	}
+
+	anchor, _ := s.windowAnchor(t.In(s.location(t)))
+	return t.Sub(anchor)%s.Frequency == 0
+}
+
+// IsActive reports whether t is within the schedule's active windows and not on one
+// of its excluded dates, in the schedule's location. Schedules without windows or
+// exclusions are always active.
+func (s *Schedule) IsActive(t time.Time) bool {
+	for _, f := range s.filters {
+		if !f.allows(t.In(s.location(t))) {
+			return false
+		}
+	}
+	return true
+}
+
+// nextTime returns the first of the times of day strictly after t, on a matching day.
+// The times are wall clock times, so they keep their time of day across DST transitions.
+func (s *Schedule) nextTime(t time.Time) time.Time {
+	for day := 0; day <= 7; day++ {
+		midnight := time.Date(t.Year(), t.Month(), t.Day()+day, 0, 0, 0, 0, t.Location())
+		if s.days != 0 && !s.days.has(midnight.Weekday()) {
+			continue
+		}
+		for _, offset := range s.times {
+			if next := atTimeOfDay(midnight, offset); next.After(t) {
+				return next
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// isDuration reports whether the occurrences are spaced by Frequency from the anchor.
+func (s *Schedule) isDuration() bool {
+	return s.Source == nil && s.days == 0 && s.period == 0 && len(s.times) == 0 &&
+		s.cron == nil && len(s.Intervals) == 0 && !s.anchored && s.Frequency > 0
+}
+
+// nextAnchored returns the first occurrence strictly after t of an interval of whole
+// days anchored at a time of day. The days are counted from the anchor's date.
+func (s *Schedule) nextAnchored(t time.Time) time.Time {
+	days := int(s.Frequency / (24 * time.Hour))
+
+	// Step back to the latest day of the cycle on or before t's date.
+	offset := (civilDay(t) - civilDay(s.anchor().In(t.Location()))) % days
+	if offset < 0 {
+		offset += days
+	}
+
+	day := time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+	next := atTimeOfDay(day, s.at)
+	for !next.After(t) {
+		day = day.AddDate(0, 0, days)
+		next = atTimeOfDay(day, s.at)
+	}
+	return next
+}
+
+// civilDay returns the number of calendar days from the Unix epoch to the date of t.
+func civilDay(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// nextInCycle returns the first occurrence of an interval cycle strictly after t.
+func (s *Schedule) nextInCycle(t time.Time) time.Time {
+	var period time.Duration
+	for _, interval := range s.Intervals {
+		period += interval
+	}
+
+	// Position of t within the current cycle.
+	pos := t.Sub(s.anchor()) % period
+	if pos < 0 {
+		pos += period
+	}
+
+	// Occurrences happen at the end of every interval of the cycle.
+	var offset time.Duration
+	for _, interval := range s.Intervals {
+		offset += interval
+		if offset > pos {
+			break
+		}
+	}
+
+	return t.Add(offset - pos)
+}
+
+// anchor returns the instant duration schedules are aligned to.
+func (s *Schedule) anchor() time.Time {
+	if s.Anchor.IsZero() {
+		return time.Unix(0, 0)
+	}
+	return s.Anchor
+}
+
+// location returns the location occurrences around t are computed in.
+func (s *Schedule) location(t time.Time) *time.Location {
+	if s.Location != nil {
+		return s.Location
+	}
+	return t.Location()
+}
+
+// nextAfter returns the first occurrence strictly after t. Unlike NextOccurrence,
+// t does not need to be an occurrence itself: duration schedules are aligned to the anchor.
+func (s *Schedule) nextAfter(t time.Time) time.Time {
+	return s.skipFiltered(s.alignedAfter(t))
+}
+
+// alignedAfter returns the first occurrence strictly after t like nextAfter, ignoring filters.
+func (s *Schedule) alignedAfter(t time.Time) time.Time {
+	t = t.In(s.location(t))
+	if !s.isDuration() {
+		return s.next(t)
+	}
+
+	anchor, open := s.windowAnchor(t)
+	if !open {
+		// The cadence restarts when the window opens.
+		return anchor
+	}
+
+	elapsed := t.Sub(anchor)
+	offset := elapsed % s.Frequency
+	if offset < 0 {
+		offset += s.Frequency
+	}
+
+	return t.Add(s.Frequency - offset)
+}
+
+// windowAnchor returns the instant the cadence of a duration schedule around t is
+// aligned to: the latest opening of its active windows at or before t, or the anchor
+// if it has no windows. If a window is closed at t, it returns the instant that window
+// next opens, and false.
+func (s *Schedule) windowAnchor(t time.Time) (time.Time, bool) {
+	anchor := s.anchor()
+	windowed := false
+	for _, f := range s.filters {
+		f, ok := f.(windowFilter)
+		if !ok {
+			continue
+		}
+		if !f.allows(t) {
+			return f.resume(t), false
+		}
+
+		// The window opened earlier today, or else yesterday if it wraps around midnight.
+		opened := atTimeOfDay(t, f.start)
+		if timeOfDay(t) < f.start {
+			opened = atTimeOfDay(t.AddDate(0, 0, -1), f.start)
+		}
+		if !windowed || opened.After(anchor) {
+			anchor = opened
+		}
+		windowed = true
+	}
+	return anchor, true
+}
+
+// Next returns the first occurrence strictly after the given time, implementing
+// OccurrenceSource. It returns false when the schedule has no further occurrences.
+func (s *Schedule) Next(after time.Time) (time.Time, bool) {
+	next := s.nextAfter(after)
+	return next, !next.IsZero()
+}
+
+// Until returns how long it is from the given time until the next occurrence. It returns
+// zero if an occurrence is due exactly at from, and a negative duration if the schedule
+// has no further occurrences.
+func (s *Schedule) Until(from time.Time) time.Duration {
+	next := s.nextAfter(from.Add(-time.Nanosecond))
+	if next.IsZero() {
+		return -1
+	}
+	return next.Sub(from)
+}
+
+// DefaultBetweenLimit caps the number of occurrences returned by Between.
+const DefaultBetweenLimit = 10000
+
+// Between returns every occurrence in [from, to), up to DefaultBetweenLimit occurrences.
+func (s *Schedule) Between(from, to time.Time) []time.Time {
+	times, _ := s.BetweenLimit(from, to, DefaultBetweenLimit)
+	return times
+}
+
+// BetweenLimit returns the occurrences in [from, to), up to limit occurrences.
+// It reports whether the result was truncated by the limit.
+func (s *Schedule) BetweenLimit(from, to time.Time, limit int) (times []time.Time, truncated bool) {
+	for next := s.nextAfter(from.Add(-time.Nanosecond)); !next.IsZero() && next.Before(to); next = s.NextOccurrence(next) {
+		if len(times) == limit {
+			return times, true
+		}
+		times = append(times, next)
+	}
+
+	return times, false
+}
+
+// FrequencyPerDay estimates how many times a day the schedule fires, on average.
+// Cron schedules are estimated from the number of values each field matches, and
+// sources by counting their occurrences over the week following the anchor.
+// Active windows and business days scale the estimate by the share of the day or
+// week they cover; excluded dates are ignored.
+func (s *Schedule) FrequencyPerDay() float64 {
+	const day = 24 * time.Hour
+
+	if s.Source != nil {
+		from := s.anchor()
+		times, truncated := s.BetweenLimit(from, from.Add(7*day), DefaultBetweenLimit)
+		if truncated {
+			// Extrapolate from the span the occurrences cover.
+			return float64(len(times)-1) * float64(day) / float64(times[len(times)-1].Sub(times[0]))
+		}
+		return float64(len(times)) / 7
+	}
+
+	var perDay float64
+	switch {
+	case s.period != 0:
+		perDay = s.period.perDay()
+	case s.cron != nil:
+		perDay = s.cron.perDay()
+	case len(s.Intervals) > 0:
+		var period time.Duration
+		for _, interval := range s.Intervals {
+			period += interval
+		}
+		perDay = float64(len(s.Intervals)) * float64(day) / float64(period)
+	case len(s.times) > 0:
+		perDay = float64(len(s.times)) * s.days.share()
+	case s.days != 0:
+		perDay = s.days.share()
+	case s.Frequency > 0:
+		perDay = float64(day) / float64(s.Frequency)
+	}
+
+	for _, f := range s.filters {
+		switch f := f.(type) {
+		case windowFilter:
+			perDay *= f.share()
+		case weekdayFilter:
+			perDay *= weekdaySet(f).share()
+		}
+	}
+	return perDay
+}
+
+// share returns the share of the week the set covers, or 1 for an empty set,
+// which restricts no days.
+func (w weekdaySet) share() float64 {
+	if w == 0 {
+		return 1
+	}
+	return float64(bits.OnesCount8(uint8(w))) / 7
+}
+
+// share returns the share of the day the window covers.
+func (f windowFilter) share() float64 {
+	length := f.end - f.start
+	if length < 0 {
+		length += 24 * time.Hour
+	}
+	return float64(length) / float64(24*time.Hour)
+}
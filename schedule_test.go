@@ -0,0 +1,332 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// Test excluded dates are skipped by calendar schedules
+func TestExcludeDatesWeekdays(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC) // Friday
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	// Monday 2024-03-11 is a holiday.
+	holiday := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	events := make(chan Event)
+	cancel, err := s.Schedule("@weekdays", func(event Event) error {
+		events <- event
+		return nil
+	}, WithExcludeDates(holiday))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	clock.Advance(holiday.Sub(clock.Now()))
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no run on the holiday, got %v", event.Time)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	tuesday := holiday.AddDate(0, 0, 1)
+	clock.Advance(tuesday.Sub(clock.Now()))
+	if event := <-events; !event.Time.Equal(tuesday) {
+		t.Fatalf("Expected first run on Tuesday %v, got %v", tuesday, event.Time)
+	}
+}
+
+// Test excluded dates skip whole days of duration schedules
+func TestExcludeDatesDuration(t *testing.T) {
+	anchor := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	ce := &Schedule{Frequency: 6 * time.Hour, Anchor: anchor}
+	ce.Exclude(time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC))
+
+	next := ce.NextOccurrence(anchor.Add(18 * time.Hour))
+	if want := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+
+	if ce.Matches(time.Date(2024, 3, 9, 6, 0, 0, 0, time.UTC)) {
+		t.Fatal("Expected occurrence on an excluded date not to match")
+	}
+
+	times := ce.Between(anchor, anchor.Add(72*time.Hour))
+	if len(times) != 8 {
+		t.Fatalf("Expected 8 occurrences outside the excluded date, got %d", len(times))
+	}
+}
+
+// Test occurrences at the edges of an active window
+func TestActiveWindowEdges(t *testing.T) {
+	day := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	ce := &Schedule{Frequency: 5 * time.Minute, Anchor: day}
+	ce.Window(9*time.Hour, 17*time.Hour)
+
+	tests := []struct {
+		prev, want time.Time
+	}{
+		// Before the window opens.
+		{day.Add(8*time.Hour + 50*time.Minute), day.Add(9 * time.Hour)},
+		// Inside the window.
+		{day.Add(9 * time.Hour), day.Add(9*time.Hour + 5*time.Minute)},
+		// The window end is exclusive.
+		{day.Add(16*time.Hour + 55*time.Minute), day.Add(33 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		if next := ce.NextOccurrence(tt.prev); !next.Equal(tt.want) {
+			t.Fatalf("Expected occurrence after %v at %v, got %v", tt.prev, tt.want, next)
+		}
+	}
+
+	if !ce.Matches(day.Add(9*time.Hour)) || ce.Matches(day.Add(17*time.Hour)) {
+		t.Fatal("Expected window start to match and window end not to match")
+	}
+}
+
+// Test a window wrapping around midnight
+func TestActiveWindowWrapping(t *testing.T) {
+	day := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	ce := &Schedule{Frequency: time.Hour, Anchor: day}
+	ce.Window(22*time.Hour, 2*time.Hour)
+
+	times := ce.Between(day, day.Add(24*time.Hour))
+	want := []time.Duration{0, time.Hour, 22 * time.Hour, 23 * time.Hour}
+	if len(times) != len(want) {
+		t.Fatalf("Expected %d occurrences, got %v", len(want), times)
+	}
+	for i, offset := range want {
+		if !times[i].Equal(day.Add(offset)) {
+			t.Fatalf("Expected occurrence %d at %v, got %v", i, day.Add(offset), times[i])
+		}
+	}
+}
+
+// Test a window allowing none of the occurrences leaves the schedule without any
+func TestActiveWindowNeverAllows(t *testing.T) {
+	day := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+
+	daily, err := Parse("@daily")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	daily.Window(9*time.Hour, 17*time.Hour)
+
+	// An empty window allows nothing.
+	empty := &Schedule{Frequency: time.Hour, Anchor: day}
+	empty.Window(9*time.Hour, 9*time.Hour)
+
+	for _, ce := range []*Schedule{daily, empty} {
+		if next := ce.NextOccurrence(day); !next.IsZero() {
+			t.Fatalf("Expected no occurrence, got %v", next)
+		}
+	}
+}
+
+// Test whether instants are within the active window and off excluded dates
+func TestIsActive(t *testing.T) {
+	day := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	ce := &Schedule{Frequency: 5 * time.Minute, Anchor: day}
+	if !ce.IsActive(day) {
+		t.Fatal("Expected a schedule without filters to be active")
+	}
+
+	ce.Window(9*time.Hour, 17*time.Hour)
+	ce.Exclude(day.AddDate(0, 0, 1))
+
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{day.Add(9*time.Hour - time.Nanosecond), false},
+		{day.Add(9 * time.Hour), true},
+		// Off the schedule's occurrences, but within the window.
+		{day.Add(12*time.Hour + 7*time.Second), true},
+		{day.Add(17*time.Hour - time.Nanosecond), true},
+		{day.Add(17 * time.Hour), false},
+		// The excluded date is dormant all day.
+		{day.Add(24*time.Hour + 12*time.Hour), false},
+		{day.Add(48*time.Hour + 12*time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		if got := ce.IsActive(tt.t); got != tt.want {
+			t.Errorf("Expected IsActive(%v) to be %v, got %v", tt.t, tt.want, got)
+		}
+	}
+}
+
+// Test a daily schedule advances by business days across a weekend and a holiday
+func TestBusinessDays(t *testing.T) {
+	friday := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	ce, err := Parse("@daily")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ce.Anchor = friday
+	ce.BusinessDays(time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)) // Tuesday
+
+	if next := ce.NextOccurrence(friday); !next.Equal(friday.AddDate(0, 0, 3)) {
+		t.Fatalf("Expected Monday after Friday, got %v", next)
+	}
+
+	times := ce.Between(friday, friday.AddDate(0, 0, 7))
+	want := []int{0, 3, 5, 6} // Friday, Monday, Wednesday and Thursday
+	if len(times) != len(want) {
+		t.Fatalf("Expected %d occurrences, got %v", len(want), times)
+	}
+	for i, days := range want {
+		if !times[i].Equal(friday.AddDate(0, 0, days)) {
+			t.Fatalf("Expected occurrence %d on %v, got %v", i, friday.AddDate(0, 0, days), times[i])
+		}
+	}
+
+	if got := ce.FrequencyPerDay(); got != 5.0/7 {
+		t.Fatalf("Expected 5/7 runs a day, got %v", got)
+	}
+}
+
+// Test the occurrence API returns when the filters allow no occurrence
+func TestScheduleFilteredOut(t *testing.T) {
+	ce, err := Parse("0 0 * * *")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ce.Window(9*time.Hour, 17*time.Hour)
+
+	from := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	if next, ok := ce.Next(from); ok {
+		t.Fatalf("Expected no next occurrence, got %v", next)
+	}
+	if d := ce.Until(from); d >= 0 {
+		t.Fatalf("Expected a negative duration, got %v", d)
+	}
+	if times := ce.Between(from, from.AddDate(1, 0, 0)); len(times) != 0 {
+		t.Fatalf("Expected no occurrences, got %v", times)
+	}
+}
+
+// Test business days leave a weekend schedule without occurrences
+func TestBusinessDaysOnWeekends(t *testing.T) {
+	ce, err := Parse("@weekends")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ce.BusinessDays()
+
+	if next := ce.NextOccurrence(time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)); !next.IsZero() {
+		t.Fatalf("Expected no occurrence, got %v", next)
+	}
+}
+
+// Test Until measures the time to the next occurrence
+func TestScheduleUntil(t *testing.T) {
+	anchor := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	ce := &Schedule{Frequency: 15 * time.Minute, Anchor: anchor}
+
+	tests := []struct {
+		from time.Time
+		want time.Duration
+	}{
+		{anchor.Add(10*time.Minute + 48*time.Second), 4*time.Minute + 12*time.Second},
+		{anchor.Add(15 * time.Minute), 0},                      // On a boundary, already due.
+		{anchor.Add(-24*time.Hour - time.Minute), time.Minute}, // Long before the anchor.
+	}
+
+	for _, tt := range tests {
+		if got := ce.Until(tt.from); got != tt.want {
+			t.Fatalf("Expected %v until the next occurrence after %v, got %v", tt.want, tt.from, got)
+		}
+	}
+
+	// Exhausted schedules report a negative duration.
+	exhausted := &Schedule{Source: &stepSource{}}
+	if got := exhausted.Until(anchor); got >= 0 {
+		t.Fatalf("Expected a negative duration, got %v", got)
+	}
+}
+
+// Test second granularity occurrences land on whole seconds across a minute boundary
+func TestNextOccurrenceSecondBoundary(t *testing.T) {
+	// A leap second was inserted at the end of 2016. Go does not model it, so the
+	// minute still has 60 seconds and the occurrences stay on the boundaries.
+	minute := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	every, err := parse("@every 1s")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	every.Anchor = minute.Add(-time.Hour)
+
+	cron, err := parse("* * * * * *")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, ce := range []*Schedule{every, cron} {
+		// Late fire times are truncated back onto the boundaries.
+		for _, prev := range []time.Time{
+			minute.Add(-time.Second),
+			minute.Add(-time.Nanosecond),
+			minute.Add(-500 * time.Millisecond),
+		} {
+			if next := ce.NextOccurrence(prev); !next.Equal(minute) {
+				t.Fatalf("Expected %q after %v at %v, got %v", ce.Expr, prev, minute, next)
+			}
+		}
+
+		times := ce.Between(minute.Add(-2*time.Second), minute.Add(2*time.Second))
+		if len(times) != 4 {
+			t.Fatalf("Expected 4 occurrences of %q around the minute, got %v", ce.Expr, times)
+		}
+		for i, next := range times {
+			if want := minute.Add(time.Duration(i-2) * time.Second); !next.Equal(want) {
+				t.Fatalf("Expected occurrence %d of %q at %v, got %v", i, ce.Expr, want, next)
+			}
+		}
+	}
+}
+
+// Test estimating how many times a day schedules fire
+func TestFrequencyPerDay(t *testing.T) {
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"@every 30m", 48},
+		{"@every 1h", 24},
+		{"@every 1m,5m", 480},
+		{"@hourly", 24},
+		{"@weekly", 1.0 / 7},
+		{"@weekdays", 5.0 / 7},
+		{"@daily@08:00,12:00,18:00", 3},
+		{"*/15 9-11 * * MON-FRI", 12 * 5.0 / 7},
+		{"0 0 1 * *", 1.0 / 31},
+	}
+
+	for _, tt := range tests {
+		schedule, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := schedule.FrequencyPerDay(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: expected %v runs a day, got %v", tt.expr, tt.want, got)
+		}
+	}
+
+	// Windows scale the estimate and sources are counted.
+	schedule, _ := Parse("@every 30m")
+	schedule.Window(9*time.Hour, 17*time.Hour)
+	if got := schedule.FrequencyPerDay(); got != 16 {
+		t.Errorf("Expected 16 runs a day within the window, got %v", got)
+	}
+
+	source := &Schedule{Source: FixedTime{Hour: 18, Location: time.UTC}}
+	if got := source.FrequencyPerDay(); got != 1 {
+		t.Errorf("Expected 1 run a day for a source, got %v", got)
+	}
+}
@@ -1,24 +1,234 @@
 package scheduler
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 // Regular expression to match predefined and custom scheduling expressions.
-var rgxp = regexp.MustCompile(`(?P<predefined>@(yearly|monthly|weekly|daily|hourly))|(?P<custom>@every (\d+(ns|us|µs|ms|s|m|h))+)`)
+var rgxp = regexp.MustCompile(`^(?:(?P<predefined>@(yearly|monthly|weekly(@(sun|mon|tue|wed|thu|fri|sat))?|weekdays|weekends|daily|hourly))(?P<times>@\d{1,2}:\d{2}(,\d{1,2}:\d{2})*)?(?P<at>:\d{1,2}(:\d{2})?)?|(?P<custom>@every (\d+(ns|us|µs|ms|s|m|h|d))+(,(\d+(ns|us|µs|ms|s|m|h|d))+)*(@\d{1,2}:\d{2})?))$`)
+
+// errZeroInterval is the error of intervals of zero length.
+var errZeroInterval = errors.New("zero interval")
+
+// dayUnit matches a number of days in an interval.
+var dayUnit = regexp.MustCompile(`(\d+)d`)
 
 // Scheduler represents a scheduling system that starts from a given time.
+//
+// Its methods are safe for concurrent use, including from within handlers: no lock of
+// the scheduler or of a Dispatcher is held while a handler runs, so a handler may
+// schedule, cancel, trigger or look up tasks, e.g. to spawn follow-up jobs. A handler
+// must not wait for its own task to stop, as Shutdown and Handle.Shutdown do.
 type Scheduler struct {
-	start time.Time
+	start      time.Time
+	clock      Clock
+	dispatcher *Dispatcher
+	ctx        context.Context
+	location   *time.Location
+	logger     *slog.Logger
+
+	// rand is the source jitter is drawn from, guarded by randMu. When it is nil,
+	// the global source is used.
+	randMu sync.Mutex
+	rand   *rand.Rand
+
+	// barrier holds tasks until Start closes it, when set by WithStartBarrier.
+	// startedAt is when it was closed.
+	barrier   chan struct{}
+	startOnce sync.Once
+	startedAt time.Time
+
+	// lastRuns persists the last successful runs of named jobs.
+	lastRuns LastRunStore
+
+	// checkDuplicates warns when a named job reuses another job's handler.
+	checkDuplicates bool
+
+	// onJobAdded and onJobRemoved observe the named jobs entering and leaving the registry.
+	onJobAdded   func(name string)
+	onJobRemoved func(name string, reason StopReason)
+
+	// middlewares wrap the handlers of tasks when they start, guarded by middlewareMu.
+	middlewareMu sync.Mutex
+	middlewares  []func(Handler) Handler
+
+	registry registry
+
+	// running counts the handlers currently executing across all tasks.
+	running atomic.Int32
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithClock sets the clock used by the scheduler. It defaults to the system clock.
+func WithClock(clock Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithLocation sets the time zone calendar schedules are computed in.
+// It defaults to the location set with SetDefaultLocation, or else the location
+// of the start time.
+func WithLocation(loc *time.Location) Option {
+	return func(s *Scheduler) {
+		s.location = loc
+	}
+}
+
+// WithLogger sets the logger the scheduler reports warnings to.
+// It defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Scheduler) {
+		s.logger = logger
+	}
+}
+
+// WithDuplicateHandlerCheck makes AddJob log a warning when the same handler function
+// is registered under two names, which is often a copy-paste mistake. Closures created
+// from the same function literal share their code and are reported as duplicates too.
+func WithDuplicateHandlerCheck() Option {
+	return func(s *Scheduler) {
+		s.checkDuplicates = true
+	}
+}
+
+// WithOnJobAdded calls fn with the name of every job added with AddJob, once it is
+// registered. A job replacing another one under the same name is reported as added.
+func WithOnJobAdded(fn func(name string)) Option {
+	return func(s *Scheduler) {
+		s.onJobAdded = fn
+	}
+}
+
+// WithOnJobRemoved calls fn with the name of every named job leaving the registry
+// because it stopped, and why it stopped. A job replaced under its name is not reported,
+// since the name stays registered. fn is called on the goroutine stopping the job.
+func WithOnJobRemoved(fn func(name string, reason StopReason)) Option {
+	return func(s *Scheduler) {
+		s.onJobRemoved = fn
+	}
+}
+
+// WithRand makes the jitter of every task draw from r, e.g. a seeded source for
+// reproducible tests. It defaults to the automatically seeded global source.
+func WithRand(r *rand.Rand) Option {
+	return func(s *Scheduler) {
+		s.rand = r
+	}
+}
+
+// randN returns a random duration in [0, n) drawn from the scheduler's source.
+func (s *Scheduler) randN(n time.Duration) time.Duration {
+	if s.rand == nil {
+		return rand.N(n)
+	}
+
+	s.randMu.Lock()
+	defer s.randMu.Unlock()
+	return time.Duration(s.rand.Int64N(int64(n)))
+}
+
+// WithStartBarrier holds every task scheduled before Start is called. Once started,
+// the held tasks are anchored to the same instant, so jobs registered one after the
+// other at startup begin ticking together.
+func WithStartBarrier() Option {
+	return func(s *Scheduler) {
+		s.barrier = make(chan struct{})
+	}
+}
+
+// Start releases the tasks held by WithStartBarrier. Tasks scheduled afterwards start
+// right away. It has no effect without a start barrier or when called again.
+func (s *Scheduler) Start() {
+	if s.barrier == nil {
+		return
+	}
+
+	s.startOnce.Do(func() {
+		s.startedAt = s.clock.Now()
+		close(s.barrier)
+	})
+}
+
+// WithDispatcher runs the scheduler's tasks on a shared Dispatcher instead of
+// starting one goroutine per task.
+func WithDispatcher(d *Dispatcher) Option {
+	return func(s *Scheduler) {
+		s.dispatcher = d
+	}
 }
 
 // New creates a new Scheduler instance with a specified start time.
-func New(start time.Time) *Scheduler {
-	return &Scheduler{start}
+func New(start time.Time, opts ...Option) *Scheduler {
+	s := &Scheduler{start: start, clock: realClock{}, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.location == nil {
+		s.location = defaultLocation.Load()
+	}
+	if s.location == nil {
+		s.location = start.Location()
+	}
+	return s
+}
+
+// NewValidated creates a new Scheduler like New, but fails with ErrIncompatibleOptions
+// if the options contradict each other.
+func NewValidated(start time.Time, opts ...Option) (*Scheduler, error) {
+	s := New(start, opts...)
+
+	// The dispatcher fires tasks on the system clock, whatever the scheduler's.
+	if s.dispatcher != nil && s.clock != Clock(realClock{}) {
+		return nil, fmt.Errorf("%w: WithDispatcher and WithClock", ErrIncompatibleOptions)
+	}
+	return s, nil
+}
+
+// NewWithContext creates a new Scheduler like New, whose tasks are all stopped
+// once ctx is cancelled. Tasks scheduled after that are stopped right away.
+func NewWithContext(ctx context.Context, start time.Time, opts ...Option) *Scheduler {
+	s := New(start, opts...)
+	s.ctx = ctx // Task contexts derive from it, so cancelling it stops them.
+	return s
+}
+
+// Use adds a middleware wrapping the handler of every task started afterwards, e.g. to
+// add logging or recover from panics without repeating it in each handler. Middlewares
+// compose in the order they were added: the first one added is the outermost.
+func (s *Scheduler) Use(middleware func(Handler) Handler) {
+	s.middlewareMu.Lock()
+	defer s.middlewareMu.Unlock()
+	s.middlewares = append(s.middlewares, middleware)
+}
+
+// wrap applies the middlewares to handler.
+func (s *Scheduler) wrap(handler Handler) Handler {
+	s.middlewareMu.Lock()
+	defer s.middlewareMu.Unlock()
+
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		handler = s.middlewares[i](handler)
+	}
+	return handler
+}
+
+// Idle reports whether no handler of the scheduler is currently executing.
+func (s *Scheduler) Idle() bool {
+	return s.running.Load() == 0
 }
 
 // Handler defines a function signature that processes scheduled events.
@@ -26,77 +236,526 @@ type Handler func(event Event) error
 
 // Event represents an occurrence of a scheduled task.
 type Event struct {
+	// Time is when the handler was invoked.
 	Time time.Time
+	// Scheduled is the occurrence the event was due at. It is zero for triggered runs.
+	Scheduled time.Time
+	// Missed is the number of occurrences skipped since the previous scheduled run
+	// because they passed while it was running. It is zero for triggered runs.
+	Missed int
+	// Handle is the task the event belongs to, e.g. to Reschedule it from the handler.
+	Handle *Handle
+	// Err is the error the handler returned for the event. It is only set on the
+	// events returned by RecentEvents.
+	Err error
+}
+
+// Metadata returns the metadata of the task the event belongs to, as set with
+// WithMetadata. It must not be modified.
+func (e Event) Metadata() map[string]string {
+	if e.Handle == nil {
+		return nil
+	}
+	return e.Handle.Metadata()
 }
 
+// ContextHandler defines a function signature that processes scheduled events with
+// the context of the run.
+type ContextHandler func(ctx context.Context, event Event) error
+
 // Schedule sets up a scheduled task based on the given expression and handler function.
 // It returns a cancel function to stop the schedule, or an error if the expression is invalid.
-func (s *Scheduler) Schedule(expr string, handler Handler) (func(), error) {
+func (s *Scheduler) Schedule(expr string, handler Handler, opts ...JobOption) (func(), error) {
 	// Parse the scheduling expression.
 	ce, err := parse(expr)
 	if err != nil {
 		return nil, err
 	}
 
-	// Determine the next occurrence of the scheduled event.
-	nextOccurrence := s.start
-	now := time.Now()
-	for nextOccurrence.Before(now) || nextOccurrence.Equal(now) {
-		nextOccurrence = nextOccurrence.Add(ce.Frequency)
+	h, err := s.schedule(ce, handler, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return h.Cancel, nil
+}
 
-	// Create a ticker that checks at the interval of the frequency.
-	ticker := time.NewTicker(ce.Frequency)
-	done := make(chan struct{})
+// ScheduleContext sets up a scheduled task like Schedule which stops once ctx is cancelled.
+// Every run gets its own context derived from ctx, cancelled once the run returns, the
+// task stops, or the timeout configured with WithRunTimeout expires.
+func (s *Scheduler) ScheduleContext(ctx context.Context, expr string, handler ContextHandler, opts ...JobOption) (func(), error) {
+	ce, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
 
-	var closed atomic.Bool
+	h, err := s.newHandle(ce, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.parent = ctx
+	h.handler = func(event Event) error {
+		runCtx, cancel := h.runContext()
+		defer cancel()
+		return handler(runCtx, event)
+	}
+	h.start()
 
-	// Goroutine to handle scheduled execution.
-	go func() {
-		for {
-			select {
-			case <-done:
-				// Cleanup and exit the goroutine.
-				closed.Store(true)
-				return
-			case t := <-ticker.C:
-				if t.Before(nextOccurrence) {
-					continue
-				}
+	return h.Cancel, nil
+}
 
-				event := Event{Time: t}
-				if err := handler(event); err != nil {
-					ticker.Stop()
-					close(done) // Close the done channel when done.
-					break
-				}
+// DelayHandler defines a function signature that processes scheduled events and
+// decides when the task runs next. A positive delay overrides the next occurrence,
+// while zero keeps the schedule's.
+type DelayHandler func(event Event) (time.Duration, error)
 
-				// Update the next occurrence.
-				nextOccurrence = ce.NextOccurrence(t)
-			}
+// ScheduleDelay sets up a scheduled task like Schedule whose handler can postpone or
+// advance its next run: the next run happens the returned delay after the run finished,
+// and the schedule resumes at its first occurrence after that. Out of band runs requested with Trigger cannot
+// override the next occurrence. Runs are always synchronous.
+func (s *Scheduler) ScheduleDelay(expr string, handler DelayHandler, opts ...JobOption) (func(), error) {
+	ce, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := s.newHandle(ce, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.async = false // The delay determines the next occurrence, which waits for the run.
+	h.handler = func(event Event) error {
+		delay, err := handler(event)
+		if !event.Scheduled.IsZero() {
+			h.delay = delay
+		}
+		return err
+	}
+	h.start()
+
+	return h.Cancel, nil
+}
+
+// BatchHandler defines a function signature that processes a batch of scheduled events.
+type BatchHandler func(events []Event) error
+
+// ScheduleBatch sets up a scheduled task whose events are accumulated and handed to
+// the handler in batches, as configured with WithBatching. Without WithBatching,
+// every batch holds a single event.
+func (s *Scheduler) ScheduleBatch(expr string, handler BatchHandler, opts ...JobOption) (func(), error) {
+	ce, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := s.newHandle(ce, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.async = false // The batch is accumulated across runs, which must not overlap.
+
+	b := &batcher{h: h, handler: handler}
+	h.handler = b.add
+	h.start()
+
+	return h.Cancel, nil
+}
+
+// batcher accumulates the events of a batched task until they are handed to its handler.
+type batcher struct {
+	h       *Handle
+	handler BatchHandler
+
+	mu     sync.Mutex
+	events []Event
+
+	// flushed is closed once the pending batch has been handed to the handler.
+	flushed chan struct{}
+}
+
+// add queues the event of a run, flushing the batch once either bound is reached. The
+// first event of a batch arms a timer flushing it when it is maxWait old, so the bound
+// holds even if no further occurrence comes in time.
+func (b *batcher) add(event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.events = append(b.events, event)
+	if len(b.events) == 1 {
+		b.flushed = make(chan struct{})
+	}
+
+	if len(b.events) >= b.h.batchSize || event.Time.Sub(b.events[0].Time) >= b.h.batchWait {
+		return b.flush()
+	}
+	if len(b.events) == 1 {
+		// The batch's age counts from its first event, which may have run late.
+		wait := b.h.batchWait - b.h.clock.Now().Sub(event.Time)
+		go b.expire(b.h.clock.NewTimer(wait), b.flushed)
+	}
+	return nil
+}
+
+// expire flushes the pending batch when its timer fires, unless it was flushed already
+// or the task stopped. The flush is recorded as a run of its own, like a triggered run.
+func (b *batcher) expire(timer Timer, flushed chan struct{}) {
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+	case <-flushed:
+		return
+	case <-b.h.done:
+		return
+	}
+
+	b.h.inflight.Add(1)
+	defer b.h.inflight.Done()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	select {
+	case <-flushed:
+		return
+	case <-b.h.done:
+		return
+	default:
+	}
+
+	event := Event{Time: b.h.clock.Now(), Handle: b.h}
+	if _, err := b.h.executeWith(b.h.scheduler.wrap(func(Event) error { return b.flush() }), event); err != nil {
+		b.h.fail(err)
+		return
+	}
+	b.h.reachedMaxRuns()
+}
+
+// flush hands the pending events to the handler. It must be called with mu held.
+func (b *batcher) flush() error {
+	events := b.events
+	b.events = nil
+	close(b.flushed)
+	return b.handler(events)
+}
+
+// ScheduleHandle sets up a scheduled task like Schedule, but returns a Handle
+// which can be used to stop the task and inspect why it stopped.
+func (s *Scheduler) ScheduleHandle(expr string, handler Handler, opts ...JobOption) (*Handle, error) {
+	ce, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.schedule(ce, handler, opts...)
+}
+
+// Spec pairs a scheduling expression with the handler it should run.
+type Spec struct {
+	Expr    string
+	Handler Handler
+	Options []JobOption
+}
+
+// ScheduleAll sets up a scheduled task for every spec. All expressions and options are
+// validated up front, so if any of them is invalid an error is returned and nothing is started.
+// It returns one cancel function per spec, in order, plus a cancel function stopping all of them.
+func (s *Scheduler) ScheduleAll(specs []Spec) ([]func(), func(), error) {
+	// Parse every expression before starting any goroutine.
+	schedules := make([]*Schedule, len(specs))
+	for i, spec := range specs {
+		ce, err := parse(spec.Expr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("spec %d: %w", i, err)
 		}
-	}()
+		schedules[i] = ce
+	}
 
-	// Cancel function to stop the scheduled execution.
-	cancel := func() {
-		// Check if the goroutine is closed.
-		if closed.Load() {
-			return
+	// Validate the options of every spec before starting any goroutine, too.
+	handles := make([]*Handle, len(specs))
+	for i, spec := range specs {
+		h, err := s.newHandle(schedules[i], spec.Handler, spec.Options...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("spec %d: %w", i, err)
 		}
+		handles[i] = h
+	}
 
-		ticker.Stop()
-		close(done) // Close the done channel to stop the goroutine.
+	cancels := make([]func(), len(specs))
+	for i, h := range handles {
+		h.start()
+		cancels[i] = h.Cancel
 	}
 
-	return cancel, nil
+	cancelAll := func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+
+	return cancels, cancelAll, nil
+}
+
+// ScheduleEvery sets up a scheduled task running every d, like "@every" expressions
+// but without formatting and parsing the duration.
+func (s *Scheduler) ScheduleEvery(d time.Duration, handler Handler, opts ...JobOption) (func(), error) {
+	if d <= 0 {
+		return nil, errors.New("invalid frequency")
+	}
+
+	h, err := s.schedule(&Schedule{Kind: KindEvery, Frequency: d}, handler, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return h.Cancel, nil
+}
+
+// ScheduleWithDelay sets up a scheduled task that first fires after the initial delay,
+// then recurs according to the expression from that first run onwards.
+func (s *Scheduler) ScheduleWithDelay(initial time.Duration, expr string, handler Handler, opts ...JobOption) (func(), error) {
+	// Clip opts, so the delay is not appended into the backing array of the caller.
+	return s.Schedule(expr, handler, append(slices.Clip(opts), WithInitialDelay(initial))...)
+}
+
+// ScheduleVerified runs the handler once inline and returns the event of that run. Only
+// if it succeeds is the task set up like Schedule; otherwise no task is started and the
+// handler's error is returned along with the event. The inline run counts towards
+// WithMaxRuns, and the first occurrence is the first one after it has finished.
+func (s *Scheduler) ScheduleVerified(expr string, handler Handler, opts ...JobOption) (func(), Event, error) {
+	ce, err := parse(expr)
+	if err != nil {
+		return nil, Event{}, err
+	}
+
+	h, err := s.newHandle(ce, handler, opts...)
+	if err != nil {
+		return nil, Event{}, err
+	}
+	h.wrapped = s.wrap(handler)
+
+	event := Event{Time: s.clock.Now(), Handle: h}
+	if err := h.invoke(event); err != nil {
+		h.fail(err)
+		return nil, event, err
+	}
+
+	if h.reachedMaxRuns() {
+		close(h.exited)
+		return h.Cancel, event, nil
+	}
+
+	// Occurrences that passed during a slow inline run are not run late.
+	h.first(s.start)
+	h.start()
+	return h.Cancel, event, nil
+}
+
+// ScheduleT sets up a scheduled task whose handler receives the given payload on every event.
+// Go does not allow type parameters on methods, so the scheduler is passed explicitly.
+func ScheduleT[T any](s *Scheduler, expr string, payload T, handler func(Event, T) error, opts ...JobOption) (func(), error) {
+	return s.Schedule(expr, func(event Event) error {
+		return handler(event, payload)
+	}, opts...)
+}
+
+// ScheduleSource sets up a scheduled task driven by the given occurrence source.
+// It returns a cancel function to stop the schedule, or an error if the source is nil.
+func (s *Scheduler) ScheduleSource(src OccurrenceSource, handler Handler, opts ...JobOption) (func(), error) {
+	if src == nil {
+		return nil, errors.New("nil occurrence source")
+	}
+
+	h, err := s.schedule(&Schedule{Kind: KindSource, Source: src}, handler, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return h.Cancel, nil
+}
+
+// schedule starts the goroutine executing handler on every occurrence of ce.
+func (s *Scheduler) schedule(ce *Schedule, handler Handler, opts ...JobOption) (*Handle, error) {
+	h, err := s.newHandle(ce, handler, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.start()
+	return h, nil
+}
+
+// newHandle creates the Handle of a task executing handler on every occurrence of ce.
+// The task does not run until the handle is started. It fails if the options are
+// incompatible.
+func (s *Scheduler) newHandle(ce *Schedule, handler Handler, opts ...JobOption) (*Handle, error) {
+	ce.Anchor = s.start
+	if ce.Location == nil {
+		ce.Location = s.location
+	}
+
+	h := &Handle{
+		scheduler:  s,
+		schedule:   ce,
+		handler:    handler,
+		clock:      s.clock,
+		dispatcher: s.dispatcher,
+		done:       make(chan struct{}),
+		trigger:    make(chan struct{}, 1),
+		advance:    make(chan struct{}, 1),
+		exited:     make(chan struct{}),
+		durations:  NewHistogram(DefaultBuckets...),
+		catchUp:    1,
+		seq:        handleSeq.Add(1),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+
+	h.first(s.start)
+
+	return h, nil
+}
+
+// first determines the first occurrence of the task, counting from the given time.
+func (h *Handle) first(from time.Time) {
+	ce := h.schedule
+
+	// Occurrences up to now have passed and are not run late, so a daily time that
+	// already passed today first fires on the next matching day.
+	h.next = from
+	now := h.clock.Now()
+	for !h.next.IsZero() && !h.next.After(now) {
+		h.next = ce.NextOccurrence(h.next)
+	}
+
+	// An initial delay replaces the first occurrence.
+	if h.initialDelay > 0 {
+		h.next = now.Add(h.initialDelay)
+	}
+
+	// Snap duration schedules to the first interval boundary after now.
+	if h.alignFirst && ce.isDuration() {
+		h.next = ce.skipFiltered(alignAfter(now.In(ce.Location), ce.Frequency))
+	}
+
+	// Later occurrences of duration schedules follow on from a moved first one.
+	if (h.initialDelay > 0 || h.alignFirst) && ce.isDuration() && !h.next.IsZero() {
+		h.anchor(h.next)
+	}
+
+	// Skipped occurrences count on from the first one, keeping its alignment.
+	for i := 0; i < h.skipFirst && !h.next.IsZero(); i++ {
+		h.next = h.schedule.NextOccurrence(h.next)
+	}
+
+	h.statsMu.Lock()
+	h.firstRun = h.next
+	h.statsMu.Unlock()
+}
+
+// alignAfter returns the first boundary strictly after t that is a multiple of d.
+// Intervals dividing a day are aligned to midnight in t's location, others to the Unix epoch.
+func alignAfter(t time.Time, d time.Duration) time.Time {
+	origin := time.Unix(0, 0).In(t.Location())
+	if (24*time.Hour)%d == 0 {
+		origin = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+
+	elapsed := t.Sub(origin)
+	return origin.Add(elapsed - elapsed%d + d)
+}
+
+// Parse analyzes the scheduling expression and returns the corresponding Schedule.
+// It returns a ParseError if the expression is invalid.
+func Parse(expr string) (*Schedule, error) {
+	return parse(expr)
+}
+
+// NextN parses the expression and returns its next n occurrences after from, or fewer
+// if the schedule runs out. Duration schedules are aligned to the Unix epoch, and
+// calendar occurrences are computed in from's location.
+func NextN(expr string, from time.Time, n int) ([]time.Time, error) {
+	ce, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var times []time.Time
+	for next, ok := ce.Next(from); ok && len(times) < n; next, ok = ce.Next(next) {
+		times = append(times, next)
+	}
+	return times, nil
 }
 
 // parse analyzes the scheduling expression and returns a corresponding Schedule.
+// Errors are ParseErrors carrying the expression.
 func parse(expr string) (*Schedule, error) {
-	// Match the expression against the regex.
-	matches := rgxp.FindStringSubmatch(expr)
+	body, loc, err := cutZone(expr)
+	var ce *Schedule
+	if err == nil {
+		ce, err = parseExpr(normalize(body))
+	}
+	if err != nil {
+		var pe ParseError
+		if errors.As(err, &pe) {
+			pe.Expr = expr
+			return nil, pe
+		}
+		return nil, err
+	}
+
+	ce.Expr = expr
+	if loc != nil {
+		ce.Location = loc
+	}
+	return ce, nil
+}
+
+// cutZone strips a crontab style TZ= or CRON_TZ= prefix, such as in
+// "CRON_TZ=America/New_York 0 9 * * *", and loads the zone it names.
+func cutZone(expr string) (string, *time.Location, error) {
+	trimmed := strings.TrimSpace(expr)
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		zoned, ok := strings.CutPrefix(trimmed, prefix)
+		if !ok {
+			continue
+		}
+
+		name, body, _ := strings.Cut(zoned, " ")
+		loc, err := time.LoadLocation(name)
+		if err != nil || name == "" {
+			return "", nil, ParseError{Field: "time zone", Category: CategoryBadZone, Err: fmt.Errorf("unknown time zone %q", name)}
+		}
+		return strings.TrimSpace(body), loc, nil
+	}
+	return expr, nil, nil
+}
+
+// parseExpr analyzes a normalized scheduling expression.
+func parseExpr(expr string) (*Schedule, error) {
+	// Expressions not starting with an alias are cron expressions.
+	if !strings.HasPrefix(strings.TrimSpace(expr), "@") {
+		spec, err := parseCron(expr)
+		if err != nil {
+			return nil, err
+		}
+		return &Schedule{Kind: KindCron, cron: spec}, nil
+	}
+
+	// Match the whole expression against the regex, so trailing input is rejected
+	// rather than ignored.
+	matches := rgxp.FindStringSubmatch(strings.TrimSpace(expr))
 	if matches == nil {
-		return nil, errors.New("invalid expression")
+		// Report the first malformed interval of an @every list, such as "10" in
+		// "@every 1m,5m,10".
+		if list, ok := strings.CutPrefix(strings.TrimSpace(expr), "@every "); ok {
+			for _, part := range strings.Split(list, ",") {
+				if _, err := parseInterval(part); err != nil {
+					return nil, ParseError{Category: CategoryBadDuration, Err: err}
+				}
+			}
+		}
+		return nil, ParseError{Category: CategoryUnknownAlias, Err: errors.New("unrecognized alias")}
 	}
 
 	// Map regex capture groups to their names.
@@ -108,48 +767,186 @@ func parse(expr string) (*Schedule, error) {
 	}
 
 	var freq time.Duration
+	var days weekdaySet
+	var period calendarPeriod
+	var kind Kind
 
 	// Handle predefined scheduling intervals.
 	if predefined, ok := mapped["predefined"]; ok && predefined != "" {
+		kind = KindPredefined
 		switch predefined {
 		case "@yearly":
-			freq = time.Hour * 24 * 365
+			period = yearly
 		case "@monthly":
-			freq = time.Hour * 24 * 30
+			period = monthly
 		case "@weekly":
-			freq = time.Hour * 24 * 7
+			period = weekly
 		case "@daily":
-			freq = time.Hour * 24
+			period = daily
 		case "@hourly":
-			freq = time.Hour
+			period = hourly
+		case "@weekdays":
+			days = newWeekdaySet(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+		case "@weekends":
+			days = newWeekdaySet(time.Saturday, time.Sunday)
+		default:
+			// Weekly on a chosen day, such as @weekly@mon.
+			if day, ok := strings.CutPrefix(predefined, "@weekly@"); ok {
+				days = newWeekdaySet(time.Weekday(dowBounds.names[day]))
+			}
+		}
+
+		// A list of times, such as @daily@08:00,12:00, fires at each of them on the alias's days.
+		if times := mapped["times"]; times != "" {
+			if (predefined != "@daily" && days == 0) || mapped["at"] != "" {
+				return nil, ParseError{Field: "time", Category: CategoryBadTime, Err: fmt.Errorf("%s does not accept times of day", predefined)}
+			}
+			offsets, err := parseTimes(strings.TrimPrefix(times, "@"))
+			if err != nil {
+				return nil, err
+			}
+			return &Schedule{Kind: kind, days: days, times: offsets}, nil
+		}
+
+		// A time suffix, such as @hourly:15 or @daily:09:30, sets when the alias fires.
+		if at := mapped["at"]; at != "" {
+			spec, err := atCron(predefined, days, at)
+			if err != nil {
+				return nil, err
+			}
+			return &Schedule{Kind: kind, cron: spec}, nil
 		}
 	}
 
 	// Handle custom time intervals.
 	if custom, ok := mapped["custom"]; ok && custom != "" {
+		kind = KindEvery
 		custom = strings.Replace(custom, "@every ", "", 1)
+
+		// An anchor suffix, such as @every 1d@03:00, aligns whole days to a time of day.
+		if interval, at, ok := strings.Cut(custom, "@"); ok {
+			return parseAnchored(interval, at)
+		}
+
+		// A comma separated list cycles through its intervals.
+		if strings.Contains(custom, ",") {
+			var intervals []time.Duration
+			for _, part := range strings.Split(custom, ",") {
+				interval, err := parseInterval(part)
+				if err != nil {
+					return nil, ParseError{Category: CategoryBadDuration, Err: err}
+				}
+				if interval == 0 {
+					return nil, ParseError{Category: CategoryBadDuration, Err: errZeroInterval}
+				}
+				intervals = append(intervals, interval)
+			}
+			return &Schedule{Kind: kind, Intervals: intervals}, nil
+		}
+
 		var err error
-		freq, err = time.ParseDuration(custom)
+		freq, err = parseInterval(custom)
 		if err != nil {
-			return nil, err
+			return nil, ParseError{Category: CategoryBadDuration, Err: err}
 		}
 	}
 
+	// Calendar schedules fire at midnight on the matching days.
+	if days != 0 {
+		return &Schedule{Kind: kind, days: days}, nil
+	}
+
+	// Calendar aliases fire at the start of every period on the wall clock.
+	if period != 0 {
+		return &Schedule{Kind: kind, period: period}, nil
+	}
+
 	// Ensure a valid frequency was determined.
 	if freq == 0 {
-		return nil, errors.New("invalid expression")
+		return nil, ParseError{Category: CategoryBadDuration, Err: errZeroInterval}
+	}
+
+	return &Schedule{Kind: kind, Frequency: freq}, nil
+}
+
+// parseInterval parses a duration like time.ParseDuration, also accepting a "d" unit
+// of 24 hours.
+func parseInterval(s string) (time.Duration, error) {
+	return time.ParseDuration(dayUnit.ReplaceAllStringFunc(s, func(days string) string {
+		n, err := strconv.Atoi(strings.TrimSuffix(days, "d"))
+		if err != nil {
+			return days // Let ParseDuration report the error.
+		}
+		return strconv.Itoa(n*24) + "h"
+	}))
+}
+
+// parseTimes parses a comma separated list of times of day "HH:MM" into sorted offsets
+// from midnight, dropping duplicates.
+func parseTimes(list string) ([]time.Duration, error) {
+	var offsets []time.Duration
+	for _, at := range strings.Split(list, ",") {
+		hourPart, minutePart, _ := strings.Cut(at, ":")
+		hour, err := parseCronValue(hourPart, hourBounds)
+		if err != nil {
+			return nil, ParseError{Field: "time", Category: CategoryBadTime, Err: err}
+		}
+		minute, err := parseCronValue(minutePart, minuteBounds)
+		if err != nil {
+			return nil, ParseError{Field: "time", Category: CategoryBadTime, Err: err}
+		}
+		offsets = append(offsets, time.Duration(hour)*time.Hour+time.Duration(minute)*time.Minute)
 	}
 
-	return &Schedule{freq}, nil
+	slices.Sort(offsets)
+	return slices.Compact(offsets), nil
 }
 
-// Schedule defines a recurring frequency for event execution.
-type Schedule struct {
-	Frequency time.Duration
+// parseAnchored parses an interval of whole days anchored at a time of day "HH:MM".
+func parseAnchored(interval, at string) (*Schedule, error) {
+	if strings.Contains(interval, ",") {
+		return nil, ParseError{Field: "anchor", Category: CategoryBadTime, Err: errors.New("cannot anchor a cycle of intervals")}
+	}
+
+	freq, err := parseInterval(interval)
+	if err != nil {
+		return nil, ParseError{Category: CategoryBadDuration, Err: err}
+	}
+	if freq <= 0 || freq%(24*time.Hour) != 0 {
+		return nil, ParseError{Field: "anchor", Category: CategoryBadTime, Err: fmt.Errorf("requires an interval of whole days, got %s", interval)}
+	}
+
+	hh, mm, _ := strings.Cut(at, ":")
+	hour, err := parseCronValue(hh, hourBounds)
+	if err != nil {
+		return nil, ParseError{Field: "anchor", Category: CategoryBadTime, Err: err}
+	}
+	minute, err := parseCronValue(mm, minuteBounds)
+	if err != nil {
+		return nil, ParseError{Field: "anchor", Category: CategoryBadTime, Err: err}
+	}
+
+	return &Schedule{
+		Kind:      KindEvery,
+		Frequency: freq,
+		anchored:  true,
+		at:        time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute,
+	}, nil
 }
 
-// NextOccurrence calculates the next scheduled execution time based on the previous one.
-func (s *Schedule) NextOccurrence(prev time.Time) (next time.Time) {
-	next = prev.Add(s.Frequency)
-	return
+// microReplacer rewrites both micro sign variants, U+00B5 (micro sign) and
+// U+03BC (Greek small letter mu), to the ASCII "us" unit.
+var microReplacer = strings.NewReplacer("\u00b5s", "us", "\u03bcs", "us")
+
+// upperUnit matches a number with an uppercase duration unit. Go durations have no
+// month unit, so "M" is unambiguously minutes.
+var upperUnit = regexp.MustCompile(`\d+(NS|US|µS|μS|MS|S|M|H|D)`)
+
+// normalize rewrites equivalent spellings in an expression to the form matched by rgxp.
+// Uppercase units in @every intervals, such as "@every 5S", are lowercased.
+func normalize(expr string) string {
+	if interval, ok := strings.CutPrefix(expr, "@every "); ok {
+		expr = "@every " + upperUnit.ReplaceAllStringFunc(interval, strings.ToLower)
+	}
+	return microReplacer.Replace(expr)
 }
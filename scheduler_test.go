@@ -2,7 +2,9 @@ package scheduler
 
 import (
 	"errors"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -21,10 +23,11 @@ func TestScheduleValid(t *testing.T) {
 	s := New(time.Now())
 
 	var wg sync.WaitGroup
+	var once sync.Once
 	wg.Add(1) // Ensure we increment before task execution
 
 	handler := func(event Event) error {
-		defer wg.Done()
+		once.Do(wg.Done) // The handler may run more than once
 		return nil
 	}
 
@@ -87,3 +90,1004 @@ func TestParseCustomDuration(t *testing.T) {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 }
+
+// Test typed payloads are not aliased when scheduling in a loop
+func TestScheduleTPayloads(t *testing.T) {
+	s := New(time.Now())
+
+	type config struct {
+		id int
+	}
+
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+
+	var cancels []func()
+	for i := 0; i < 5; i++ {
+		cancel, err := ScheduleT(s, "@every 50ms", config{id: i}, func(event Event, cfg config) error {
+			mu.Lock()
+			seen[cfg.id] = true
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		cancels = append(cancels, cancel)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	for _, cancel := range cancels {
+		cancel()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 0; i < 5; i++ {
+		if !seen[i] {
+			t.Fatalf("Expected payload %d to be delivered", i)
+		}
+	}
+}
+
+// Test batch scheduling with a valid set of specs
+func TestScheduleAll(t *testing.T) {
+	s := New(time.Now())
+
+	var a, b atomic.Int32
+	cancels, cancelAll, err := s.ScheduleAll([]Spec{
+		{Expr: "@every 50ms", Handler: func(event Event) error { a.Add(1); return nil }},
+		{Expr: "@every 50ms", Handler: func(event Event) error { b.Add(1); return nil }},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(cancels) != 2 {
+		t.Fatalf("Expected 2 cancel functions, got %d", len(cancels))
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	cancelAll()
+
+	if a.Load() == 0 || b.Load() == 0 {
+		t.Fatalf("Expected both handlers to run, got %d and %d", a.Load(), b.Load())
+	}
+}
+
+// Test batch scheduling fails fast on an invalid spec
+func TestScheduleAllInvalid(t *testing.T) {
+	s := New(time.Now())
+
+	var count atomic.Int32
+	handler := func(event Event) error {
+		count.Add(1)
+		return nil
+	}
+
+	_, _, err := s.ScheduleAll([]Spec{
+		{Expr: "@every 10ms", Handler: handler},
+		{Expr: "invalid", Handler: handler},
+		{Expr: "@every 10ms", Handler: handler},
+	})
+	if err == nil {
+		t.Fatal("Expected error for invalid spec, got nil")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if c := count.Load(); c != 0 {
+		t.Fatalf("Expected no handler to start, ran %d times", c)
+	}
+}
+
+// Test weekday and weekend aliases
+func TestParseWeekdaysWeekends(t *testing.T) {
+	// 2024-03-08 is a Friday.
+	friday := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"@weekdays", time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"@weekends", time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		ce, err := parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", tt.expr, err)
+		}
+
+		next := ce.NextOccurrence(friday)
+		if !next.Equal(tt.want) {
+			t.Fatalf("Expected %s to fire at %v, got %v", tt.expr, tt.want, next)
+		}
+	}
+
+	// Consecutive weekend occurrences are Saturday, Sunday, then the next Saturday.
+	ce, _ := parse("@weekends")
+	next := ce.NextOccurrence(friday)
+	for _, want := range []time.Weekday{time.Sunday, time.Saturday} {
+		next = ce.NextOccurrence(next)
+		if next.Weekday() != want {
+			t.Fatalf("Expected %v, got %v", want, next.Weekday())
+		}
+	}
+}
+
+// Test weekly schedules on a chosen weekday across month boundaries
+func TestParseWeeklyWeekday(t *testing.T) {
+	// 2024-03-27 is a Wednesday.
+	from := time.Date(2024, 3, 27, 10, 0, 0, 0, time.UTC)
+
+	ce, err := parse("@weekly@mon")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ce.Kind != KindPredefined {
+		t.Fatalf("Expected kind %v, got %v", KindPredefined, ce.Kind)
+	}
+
+	next := from
+	for _, want := range []time.Time{
+		time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 4, 8, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC),
+	} {
+		next = ce.NextOccurrence(next)
+		if !next.Equal(want) {
+			t.Fatalf("Expected %v, got %v", want, next)
+		}
+	}
+
+	// A Sunday schedule crossing from April into May.
+	ce, _ = parse("@weekly@sun")
+	next = ce.NextOccurrence(time.Date(2024, 4, 28, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC); !next.Equal(want) || next.Weekday() != time.Sunday {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+}
+
+// Test time suffixes on predefined aliases
+func TestParsePredefinedAt(t *testing.T) {
+	from := time.Date(2024, 3, 8, 10, 45, 0, 0, time.UTC) // Friday
+
+	tests := []struct {
+		expr string
+		want []time.Time
+	}{
+		{"@hourly:30", []time.Time{
+			time.Date(2024, 3, 8, 11, 30, 0, 0, time.UTC),
+			time.Date(2024, 3, 8, 12, 30, 0, 0, time.UTC),
+		}},
+		{"@daily:09:30", []time.Time{
+			time.Date(2024, 3, 9, 9, 30, 0, 0, time.UTC),
+			time.Date(2024, 3, 10, 9, 30, 0, 0, time.UTC),
+		}},
+		{"@weekdays:08:00", []time.Time{
+			time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 12, 8, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	for _, tt := range tests {
+		ce, err := parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", tt.expr, err)
+		}
+		if ce.Kind != KindPredefined {
+			t.Fatalf("Expected kind %v for %s, got %v", KindPredefined, tt.expr, ce.Kind)
+		}
+
+		next := from
+		for _, want := range tt.want {
+			next = ce.NextOccurrence(next)
+			if !next.Equal(want) {
+				t.Fatalf("Expected %s to fire at %v, got %v", tt.expr, want, next)
+			}
+		}
+	}
+
+	for _, expr := range []string{"@hourly:60", "@hourly:10:30", "@daily:30", "@daily:24:00", "@yearly:10:00"} {
+		if _, err := parse(expr); err == nil {
+			t.Fatalf("Expected error for %s", expr)
+		}
+	}
+}
+
+// Test matching timestamps against a duration schedule
+func TestScheduleMatchesDuration(t *testing.T) {
+	anchor := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	ce := &Schedule{Frequency: 15 * time.Minute, Anchor: anchor}
+
+	if !ce.Matches(anchor.Add(45 * time.Minute)) {
+		t.Fatal("Expected aligned timestamp to match")
+	}
+	if !ce.Matches(anchor.Add(-15 * time.Minute)) {
+		t.Fatal("Expected aligned timestamp before the anchor to match")
+	}
+	if ce.Matches(anchor.Add(10 * time.Minute)) {
+		t.Fatal("Expected unaligned timestamp not to match")
+	}
+}
+
+// Test matching timestamps against a calendar schedule
+func TestScheduleMatchesCalendar(t *testing.T) {
+	ce, err := parse("@weekdays")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// 2024-03-08 is a Friday.
+	if !ce.Matches(time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("Expected Friday midnight to match")
+	}
+	if ce.Matches(time.Date(2024, 3, 8, 0, 0, 1, 0, time.UTC)) {
+		t.Fatal("Expected Friday past midnight not to match")
+	}
+	if ce.Matches(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("Expected Saturday midnight not to match")
+	}
+}
+
+// Test ScheduleEvery fires like the equivalent @every expression
+func TestScheduleEvery(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	fromDuration := make(chan Event)
+	fromExpr := make(chan Event)
+
+	cancel1, err := s.ScheduleEvery(1500*time.Millisecond, func(event Event) error {
+		fromDuration <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel1()
+
+	cancel2, err := s.Schedule("@every 1s500ms", func(event Event) error {
+		fromExpr <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel2()
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(1500 * time.Millisecond)
+		a, b := <-fromDuration, <-fromExpr
+		if !a.Time.Equal(b.Time) {
+			t.Fatalf("Expected identical runs, got %v and %v", a.Time, b.Time)
+		}
+	}
+
+	if _, err := s.ScheduleEvery(0, func(event Event) error { return nil }); err == nil {
+		t.Fatal("Expected error for non-positive duration, got nil")
+	}
+}
+
+// Test calendar occurrences across a DST boundary
+func TestNextOccurrenceInDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	ce, err := parse("@weekdays")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Clocks move forward on Sunday 2024-03-10, so Friday to Monday midnight is 71 hours.
+	friday := time.Date(2024, 3, 8, 0, 0, 0, 0, ny)
+	next := ce.NextOccurrenceIn(friday.UTC(), ny)
+	if want := time.Date(2024, 3, 11, 0, 0, 0, 0, ny); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+	if gap := next.Sub(friday); gap != 71*time.Hour {
+		t.Fatalf("Expected a 71h gap, got %v", gap)
+	}
+
+	// The schedule's own location is used by NextOccurrence.
+	ce.Location = ny
+	if next := ce.NextOccurrence(friday.UTC()); next.Hour() != 0 || next.Location() != ny {
+		t.Fatalf("Expected midnight in New York, got %v", next)
+	}
+}
+
+// Test predefined aliases fire at the start of their calendar period
+func TestCalendarAliases(t *testing.T) {
+	from := time.Date(2024, 3, 8, 10, 45, 0, 0, time.UTC) // Friday
+
+	tests := []struct {
+		expr string
+		want []time.Time
+	}{
+		{"@hourly", []time.Time{
+			time.Date(2024, 3, 8, 11, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC),
+		}},
+		{"@daily", []time.Time{
+			time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@weekly", []time.Time{
+			time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@monthly", []time.Time{
+			time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@yearly", []time.Time{
+			time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	for _, tt := range tests {
+		ce, err := parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", tt.expr, err)
+		}
+
+		next := from
+		for _, want := range tt.want {
+			next = ce.NextOccurrence(next)
+			if !next.Equal(want) {
+				t.Fatalf("Expected %s to fire at %v, got %v", tt.expr, want, next)
+			}
+		}
+	}
+}
+
+// Test @daily keeps firing at midnight and @hourly on the hour across a DST transition
+func TestCalendarAliasesAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	start := time.Date(2024, 3, 9, 0, 0, 0, 0, ny)
+	s := New(start, WithLocation(ny), WithClock(NewFakeClock(start)))
+
+	h, err := s.ScheduleHandle("@daily", func(event Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	// Clocks move forward on 2024-03-10, which is only 23 hours long.
+	next := h.next
+	for _, day := range []int{10, 11, 12} {
+		if want := time.Date(2024, 3, day, 0, 0, 0, 0, ny); !next.Equal(want) {
+			t.Fatalf("Expected %v, got %v", want, next)
+		}
+		next = h.schedule.NextOccurrence(next)
+	}
+
+	// Hours stay 60 minutes apart, skipping 02:00 that morning.
+	ce, err := parse("@hourly")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ce.Location = ny
+	next = time.Date(2024, 3, 10, 1, 0, 0, 0, ny)
+	if next = ce.NextOccurrence(next); !next.Equal(time.Date(2024, 3, 10, 3, 0, 0, 0, ny)) || next.Minute() != 0 {
+		t.Fatalf("Expected 03:00 after the transition, got %v", next)
+	}
+}
+
+// Test the scheduler's location is applied to calendar schedules
+func TestWithLocation(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	start := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	s := New(start, WithLocation(ny), WithClock(NewFakeClock(start)))
+
+	h, err := s.ScheduleHandle("@weekends", func(event Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	if want := time.Date(2024, 3, 9, 0, 0, 0, 0, ny); !h.next.Equal(want) {
+		t.Fatalf("Expected first run at %v, got %v", want, h.next)
+	}
+}
+
+// Test both micro sign code points are accepted
+func TestParseMicroseconds(t *testing.T) {
+	for _, expr := range []string{
+		"@every 500µs", // Micro sign
+		"@every 500μs", // Greek small letter mu
+		"@every 500us",
+	} {
+		ce, err := parse(expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", expr, err)
+		}
+		if ce.Frequency != 500*time.Microsecond {
+			t.Fatalf("Expected 500µs for %q, got %v", expr, ce.Frequency)
+		}
+	}
+}
+
+// Test uppercase duration units are accepted
+func TestParseUppercaseUnits(t *testing.T) {
+	for expr, want := range map[string]time.Duration{
+		"@every 5S":    5 * time.Second,
+		"@every 2H":    2 * time.Hour,
+		"@every 10M":   10 * time.Minute,
+		"@every 1H30M": 90 * time.Minute,
+		"@every 250MS": 250 * time.Millisecond,
+		"@every 1D":    24 * time.Hour,
+	} {
+		ce, err := parse(expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", expr, err)
+		}
+		if ce.Frequency != want {
+			t.Fatalf("Expected %v for %q, got %v", want, expr, ce.Frequency)
+		}
+		if ce.Expr != expr {
+			t.Fatalf("Expected expression %q to be kept, got %q", expr, ce.Expr)
+		}
+	}
+}
+
+// Test listing occurrences within a window
+func TestScheduleBetween(t *testing.T) {
+	day := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	ce := &Schedule{Frequency: time.Hour, Anchor: day.Add(-48 * time.Hour)}
+
+	times := ce.Between(day, day.Add(24*time.Hour))
+	if len(times) != 24 {
+		t.Fatalf("Expected 24 occurrences, got %d", len(times))
+	}
+	if !times[0].Equal(day) || !times[23].Equal(day.Add(23*time.Hour)) {
+		t.Fatalf("Expected occurrences from 00:00 to 23:00, got %v to %v", times[0], times[23])
+	}
+
+	// Unaligned window bounds.
+	times = ce.Between(day.Add(30*time.Minute), day.Add(3*time.Hour))
+	if len(times) != 2 || !times[0].Equal(day.Add(time.Hour)) {
+		t.Fatalf("Expected 01:00 and 02:00, got %v", times)
+	}
+}
+
+// Test truncation of pathological windows
+func TestScheduleBetweenLimit(t *testing.T) {
+	ce := &Schedule{Frequency: time.Nanosecond}
+	from := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+
+	times, truncated := ce.BetweenLimit(from, from.Add(time.Hour), 100)
+	if !truncated || len(times) != 100 {
+		t.Fatalf("Expected 100 truncated occurrences, got %d (truncated %v)", len(times), truncated)
+	}
+
+	if _, truncated := ce.BetweenLimit(from, from.Add(50), 100); truncated {
+		t.Fatal("Expected small window not to be truncated")
+	}
+}
+
+// Test the kind of each expression form
+func TestParseKind(t *testing.T) {
+	tests := []struct {
+		expr string
+		kind Kind
+	}{
+		{"@daily", KindPredefined},
+		{"@hourly", KindPredefined},
+		{"@weekdays", KindPredefined},
+		{"@every 5m", KindEvery},
+		{"@every 10h20m5s100ms1200ns", KindEvery},
+	}
+
+	for _, tt := range tests {
+		ce, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", tt.expr, err)
+		}
+		if ce.Kind != tt.kind {
+			t.Fatalf("Expected kind %v for %s, got %v", tt.kind, tt.expr, ce.Kind)
+		}
+	}
+}
+
+// Test cycling through a list of intervals
+func TestParseIntervalCycle(t *testing.T) {
+	ce, err := Parse("@every 1m,5m,10m")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	ce.Anchor = start
+
+	// The cycle wraps around after the last interval.
+	next := start
+	for i, gap := range []time.Duration{
+		time.Minute, 5 * time.Minute, 10 * time.Minute,
+		time.Minute, 5 * time.Minute, 10 * time.Minute,
+	} {
+		following := ce.NextOccurrence(next)
+		if following.Sub(next) != gap {
+			t.Fatalf("Expected gap %d to be %v, got %v", i, gap, following.Sub(next))
+		}
+		next = following
+	}
+
+	if !ce.Matches(start.Add(6*time.Minute)) || ce.Matches(start.Add(7*time.Minute)) {
+		t.Fatal("Expected only cycle occurrences to match")
+	}
+
+	if _, err := Parse("@every 1m,0s"); err == nil {
+		t.Fatal("Expected error for zero interval, got nil")
+	}
+}
+
+// Test no handler runs before Start and held tasks start on a shared anchor
+func TestStartBarrier(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock), WithStartBarrier())
+	defer s.Stop()
+
+	events := make(chan Event, 10)
+	handler := func(event Event) error {
+		events <- event
+		return nil
+	}
+
+	if _, err := s.Schedule("@every 1m", handler); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.Advance(30 * time.Second)
+	if _, err := s.AddJob("late", "@every 1m", handler); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Both would have fired by now without the barrier.
+	clock.Advance(5 * time.Minute)
+	time.Sleep(20 * time.Millisecond)
+	if n := len(events); n != 0 {
+		t.Fatalf("Expected no runs before Start, got %d", n)
+	}
+
+	started := clock.Now()
+	s.Start()
+	time.Sleep(20 * time.Millisecond)
+
+	clock.Advance(time.Minute)
+	for i := 0; i < 2; i++ {
+		if event := <-events; !event.Scheduled.Equal(started.Add(time.Minute)) {
+			t.Fatalf("Expected both tasks to fire at %v, got %v", started.Add(time.Minute), event.Scheduled)
+		}
+	}
+}
+
+// Test a task held by the start barrier can be shut down before Start
+func TestStartBarrierShutdown(t *testing.T) {
+	s := New(time.Now(), WithStartBarrier())
+
+	h, err := s.ScheduleHandle("@every 1ms", func(event Event) error {
+		t.Error("Unexpected run")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h.Shutdown()
+	s.Start()
+	if h.Reason() != Cancelled {
+		t.Fatalf("Expected reason %v, got %v", Cancelled, h.Reason())
+	}
+}
+
+// Test NextN previews the next occurrences of an expression
+func TestNextN(t *testing.T) {
+	from := time.Date(2024, 3, 8, 10, 7, 0, 0, time.UTC)
+
+	times, err := NextN("*/15 * * * *", from, 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []time.Time{
+		time.Date(2024, 3, 8, 10, 15, 0, 0, time.UTC),
+		time.Date(2024, 3, 8, 10, 30, 0, 0, time.UTC),
+		time.Date(2024, 3, 8, 10, 45, 0, 0, time.UTC),
+	}
+	if !slices.EqualFunc(times, want, time.Time.Equal) {
+		t.Fatalf("Expected %v, got %v", want, times)
+	}
+
+	times, err = NextN("@every 1h", from, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(times) != 2 || !times[0].Equal(from.Truncate(time.Hour).Add(time.Hour)) {
+		t.Fatalf("Expected hourly occurrences aligned to the hour, got %v", times)
+	}
+
+	for _, expr := range []string{"", "@every", "61 * * * *", "@never"} {
+		if _, err := NextN(expr, from, 3); err == nil {
+			t.Fatalf("Expected error for %q", expr)
+		}
+	}
+}
+
+// Test ScheduleVerified runs the first iteration inline before starting the task
+func TestScheduleVerified(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 10)
+	cancel, first, err := s.ScheduleVerified("@every 1m", func(event Event) error {
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	if !first.Time.Equal(start) || !first.Scheduled.IsZero() {
+		t.Fatalf("Expected an inline run at %v, got %+v", start, first)
+	}
+	<-events
+
+	clock.Advance(time.Minute)
+	if event := <-events; !event.Scheduled.Equal(start.Add(time.Minute)) {
+		t.Fatalf("Expected the recurring task to fire at %v, got %v", start.Add(time.Minute), event.Scheduled)
+	}
+}
+
+// Test the inline run of ScheduleVerified counts towards the maximum number of runs
+func TestScheduleVerifiedMaxRuns(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	var runs atomic.Int32
+	_, first, err := s.ScheduleVerified("@every 1m", func(event Event) error {
+		runs.Add(1)
+		return nil
+	}, WithMaxRuns(1))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if reason, _ := first.Handle.Wait(); reason != Exhausted {
+		t.Fatalf("Expected reason %v, got %v", Exhausted, reason)
+	}
+	clock.Advance(time.Minute)
+	time.Sleep(20 * time.Millisecond)
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("Expected only the inline run, got %d runs", n)
+	}
+}
+
+// Test the first occurrence of ScheduleVerified follows a slow inline run
+func TestScheduleVerifiedSlowRun(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	cancel, first, err := s.ScheduleVerified("@every 1m", func(event Event) error {
+		if event.Scheduled.IsZero() {
+			clock.Advance(90 * time.Second)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	if want := start.Add(2 * time.Minute); !first.Handle.FirstRun().Equal(want) {
+		t.Fatalf("Expected the first run at %v, got %v", want, first.Handle.FirstRun())
+	}
+}
+
+// Test ScheduleVerified starts nothing when the first run fails
+func TestScheduleVerifiedFailure(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	errFail := errors.New("misconfigured")
+	var runs atomic.Int32
+	cancel, _, err := s.ScheduleVerified("@every 1m", func(event Event) error {
+		runs.Add(1)
+		return errFail
+	})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("Expected %v, got %v", errFail, err)
+	}
+	if cancel != nil {
+		t.Fatal("Expected no cancel function")
+	}
+
+	clock.Advance(time.Minute)
+	time.Sleep(20 * time.Millisecond)
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("Expected only the inline run, got %d runs", n)
+	}
+	if !s.Idle() || len(s.tasks()) != 0 {
+		t.Fatal("Expected no task to be started")
+	}
+}
+
+// Test daily intervals anchored at a time of day, crossing midnight
+func TestParseEveryAnchored(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	start := time.Date(2024, 3, 8, 22, 0, 0, 0, ny)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event)
+	h, err := s.ScheduleHandle("@every 1d@03:00", func(event Event) error {
+		events <- event
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	// The second occurrence falls on the day daylight saving time starts.
+	for _, want := range []time.Time{
+		time.Date(2024, 3, 9, 3, 0, 0, 0, ny),
+		time.Date(2024, 3, 10, 3, 0, 0, 0, ny),
+		time.Date(2024, 3, 11, 3, 0, 0, 0, ny),
+	} {
+		clock.Advance(want.Sub(clock.Now()))
+		if event := <-events; !event.Scheduled.Equal(want) {
+			t.Fatalf("Expected run at %v, got %v", want, event.Scheduled)
+		}
+	}
+
+	// Multi-day intervals count the days from the scheduler's start date.
+	ce, err := parse("@every 2d@03:00")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ce.Anchor = start
+	next := ce.NextOccurrence(time.Date(2024, 3, 9, 3, 0, 0, 0, ny))
+	if want := time.Date(2024, 3, 10, 3, 0, 0, 0, ny); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+	if !ce.Matches(next) || ce.Matches(next.AddDate(0, 0, 1)) {
+		t.Fatal("Expected only every other day to match")
+	}
+
+	for _, expr := range []string{"@every 90m@03:00", "@every 36h@03:00", "@every 1d@24:00", "@every 1d@03:60", "@every 1d,2d@03:00"} {
+		if _, err := parse(expr); err == nil {
+			t.Fatalf("Expected error for %s", expr)
+		}
+	}
+}
+
+// Test a time zone prefix overrides the scheduler's location
+func TestParseZonePrefix(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	from := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	for _, expr := range []string{"CRON_TZ=America/New_York 0 9 * * *", "TZ=America/New_York @daily:09:00"} {
+		ce, err := parse(expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", expr, err)
+		}
+		if ce.Location == nil || ce.Location.String() != ny.String() {
+			t.Fatalf("Expected location %v for %q, got %v", ny, expr, ce.Location)
+		}
+
+		// 12:00 UTC is 07:00 in New York, so 09:00 is still ahead that day.
+		want := time.Date(2024, 3, 8, 9, 0, 0, 0, ny)
+		if next := ce.NextOccurrence(from); !next.Equal(want) {
+			t.Fatalf("Expected next occurrence of %q at %v, got %v", expr, want, next)
+		}
+	}
+
+	// The prefix wins over the scheduler's location.
+	s := New(from, WithClock(NewFakeClock(from)), WithLocation(time.UTC))
+	h, err := s.ScheduleHandle("TZ=America/New_York 0 9 * * *", func(Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+	if want := time.Date(2024, 3, 8, 9, 0, 0, 0, ny); !h.FirstRun().Equal(want) {
+		t.Fatalf("Expected first run at %v, got %v", want, h.FirstRun())
+	}
+}
+
+// Test an unknown time zone prefix is rejected
+func TestParseZonePrefixUnknown(t *testing.T) {
+	for _, expr := range []string{"TZ=Mars/Olympus_Mons 0 9 * * *", "CRON_TZ= @daily"} {
+		_, err := parse(expr)
+		var pe ParseError
+		if !errors.As(err, &pe) {
+			t.Fatalf("Expected a ParseError for %q, got %v", expr, err)
+		}
+		if pe.Category != CategoryBadZone || pe.Expr != expr {
+			t.Fatalf("Expected a %v error for %q, got %+v", CategoryBadZone, expr, pe)
+		}
+	}
+}
+
+// Test delays returned by the handler override the next occurrence
+func TestScheduleDelay(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event, 1)
+	delays := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	cancel, err := s.ScheduleDelay("@every 1h", func(event Event) (time.Duration, error) {
+		var delay time.Duration
+		if len(delays) > 0 {
+			delay, delays = delays[0], delays[1:]
+		}
+		events <- event
+		return delay, nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	// Increasing delays, then back to the hourly cadence.
+	first := start.Add(time.Hour)
+	for _, want := range []time.Time{
+		first,
+		first.Add(time.Second),
+		first.Add(3 * time.Second),
+		first.Add(6 * time.Second),
+		first.Add(time.Hour),
+	} {
+		waitArmed(t, clock, want)
+		clock.Advance(want.Sub(clock.Now()))
+		if event := <-events; !event.Scheduled.Equal(want) {
+			t.Fatalf("Expected run scheduled at %v, got %v", want, event.Scheduled)
+		}
+	}
+}
+
+// Test a daily time already passed at startup is not run late but waits for the next day
+func TestFirstOccurrencePassedToday(t *testing.T) {
+	// 2024-03-07 is a Thursday, 2024-03-08 a Friday.
+	tests := []struct {
+		name        string
+		start, want time.Time
+	}{
+		{"before", time.Date(2024, 3, 7, 8, 0, 0, 0, time.UTC), time.Date(2024, 3, 7, 9, 0, 0, 0, time.UTC)},
+		{"at", time.Date(2024, 3, 7, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC)},
+		{"after", time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC)},
+		{"after on Friday", time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The scheduler may have been created earlier the same day.
+			midnight := time.Date(tt.start.Year(), tt.start.Month(), tt.start.Day(), 0, 0, 0, 0, time.UTC)
+			for _, created := range []time.Time{tt.start, midnight} {
+				s := New(created, WithClock(NewFakeClock(tt.start)))
+				for _, expr := range []string{"@weekdays:09:00", "0 9 * * 1-5"} {
+					h, err := s.ScheduleHandle(expr, func(Event) error { return nil })
+					if err != nil {
+						t.Fatalf("Unexpected error: %v", err)
+					}
+					h.Cancel()
+
+					if !h.FirstRun().Equal(tt.want) {
+						t.Fatalf("Expected %q created at %v to first run at %v, got %v", expr, created, tt.want, h.FirstRun())
+					}
+				}
+			}
+		})
+	}
+}
+
+// Test a list of daily times rolls over to the next day after the last one
+func TestParseDailyTimes(t *testing.T) {
+	// 2024-03-08 is a Friday.
+	day := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	at := func(days int, hour int) time.Time {
+		return day.AddDate(0, 0, days).Add(time.Duration(hour) * time.Hour)
+	}
+
+	tests := []struct {
+		expr string
+		want []time.Time
+	}{
+		{"@daily@08:00,12:00,18:00", []time.Time{at(0, 12), at(0, 18), at(1, 8), at(1, 12), at(1, 18), at(2, 8)}},
+		{"@daily@18:00,08:00,12:00,08:00", []time.Time{at(0, 12), at(0, 18), at(1, 8), at(1, 12), at(1, 18), at(2, 8)}},
+		{"@weekdays@08:00,18:00", []time.Time{at(0, 18), at(3, 8), at(3, 18), at(4, 8)}},
+	}
+
+	for _, tt := range tests {
+		ce, err := parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", tt.expr, err)
+		}
+
+		prev := at(0, 9)
+		for _, want := range tt.want {
+			next := ce.NextOccurrence(prev)
+			if !next.Equal(want) {
+				t.Fatalf("Expected %q after %v at %v, got %v", tt.expr, prev, want, next)
+			}
+			prev = next
+		}
+
+		if !ce.Matches(at(3, 8)) || ce.Matches(at(3, 9)) {
+			t.Fatalf("Expected %q to match only its times of day", tt.expr)
+		}
+	}
+
+	for _, expr := range []string{"@hourly@08:00", "@weekly@08:00", "@daily@24:00", "@daily@08:60", "@daily@08:00:30"} {
+		var pe ParseError
+		if _, err := parse(expr); !errors.As(err, &pe) || pe.Category != CategoryBadTime {
+			t.Fatalf("Expected a %v error for %q, got %v", CategoryBadTime, expr, err)
+		}
+	}
+
+	// Trailing input the alias cannot match is rejected rather than ignored.
+	for _, expr := range []string{"@daily@08:00,12:00,18:0", "@daily:09:30,10:00", "@daily@08:00,", "@daily@08:00 tomorrow"} {
+		if _, err := parse(expr); err == nil {
+			t.Fatalf("Expected error for %q", expr)
+		}
+	}
+}
+
+// Test middlewares wrap handlers in the order they were added
+func TestUse(t *testing.T) {
+	s := New(time.Now())
+
+	var calls []string
+	for _, name := range []string{"outer", "inner"} {
+		s.Use(func(next Handler) Handler {
+			return func(event Event) error {
+				calls = append(calls, name+" before")
+				err := next(event)
+				calls = append(calls, name+" after")
+				return err
+			}
+		})
+	}
+
+	done := make(chan struct{})
+	h, err := s.ScheduleHandle("@every 1h", func(Event) error {
+		calls = append(calls, "handler")
+		close(done)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	h.Trigger()
+	<-done
+	h.Shutdown()
+
+	want := []string{"outer before", "inner before", "handler", "inner after", "outer after"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("Expected calls %v, got %v", want, calls)
+	}
+}
@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// SchedulerSnapshot is a point in time view of a scheduler, suitable for health endpoints.
+type SchedulerSnapshot struct {
+	// Tasks is the number of running tasks, named or not.
+	Tasks int `json:"tasks"`
+	// Jobs are the named jobs, sorted by name.
+	Jobs []JobSnapshot `json:"jobs"`
+}
+
+// JobSnapshot is a point in time view of a named job.
+type JobSnapshot struct {
+	Name    string    `json:"name"`
+	Expr    string    `json:"expr"`
+	Enabled bool      `json:"enabled"`
+	NextRun time.Time `json:"next_run"`
+	LastRun time.Time `json:"last_run"`
+	Runs    int       `json:"runs"`
+	Errors  int       `json:"errors"`
+}
+
+// Snapshot returns the state of the scheduler's tasks and named jobs.
+func (s *Scheduler) Snapshot() SchedulerSnapshot {
+	s.registry.mu.Lock()
+	defer s.registry.mu.Unlock()
+
+	snapshot := SchedulerSnapshot{
+		Tasks: len(s.registry.tasks),
+		Jobs:  make([]JobSnapshot, 0, len(s.registry.jobs)),
+	}
+	for name, h := range s.registry.jobs {
+		stats := h.Stats()
+		snapshot.Jobs = append(snapshot.Jobs, JobSnapshot{
+			Name:    name,
+			Expr:    h.Expr(),
+			Enabled: h.Enabled(),
+			NextRun: h.NextRun(),
+			LastRun: stats.LastRun,
+			Runs:    stats.Runs,
+			Errors:  stats.Errors,
+		})
+	}
+	sort.Slice(snapshot.Jobs, func(i, j int) bool { return snapshot.Jobs[i].Name < snapshot.Jobs[j].Name })
+
+	return snapshot
+}
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the snapshot reports every job and marshals to JSON
+func TestSnapshotJSON(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+	defer s.Stop()
+
+	ran := make(chan struct{}, 10)
+	if _, err := s.AddJob("cleanup", "@every 1m", func(event Event) error {
+		ran <- struct{}{}
+		return errors.New("disk busy")
+	}, WithDisabled()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	report, err := s.AddJob("report", "@every 1m", func(event Event) error {
+		ran <- struct{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.ScheduleHandle("@hourly", func(event Event) error { return nil }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	<-ran
+	waitRuns(t, report, 1)
+
+	snapshot := s.Snapshot()
+	if snapshot.Tasks != 3 || len(snapshot.Jobs) != 2 {
+		t.Fatalf("Expected 3 tasks and 2 jobs, got %+v", snapshot)
+	}
+
+	want := JobSnapshot{
+		Name:    "report",
+		Expr:    "@every 1m",
+		Enabled: true,
+		NextRun: start.Add(2 * time.Minute),
+		LastRun: start.Add(time.Minute),
+		Runs:    1,
+	}
+	if got := snapshot.Jobs[1]; got != want {
+		t.Fatalf("Expected %+v, got %+v", want, got)
+	}
+	if got := snapshot.Jobs[0]; got.Name != "cleanup" || got.Enabled || got.Runs != 0 {
+		t.Fatalf("Expected disabled cleanup job without runs, got %+v", got)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, field := range []string{`"tasks":3`, `"name":"report"`, `"next_run":"2024-03-08T10:02:00Z"`, `"runs":1`} {
+		if !strings.Contains(string(data), field) {
+			t.Fatalf("Expected %s in %s", field, data)
+		}
+	}
+}
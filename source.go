@@ -0,0 +1,90 @@
+package scheduler
+
+import "time"
+
+// OccurrenceSource produces the occurrences of a schedule. It allows external
+// providers (e.g. solar events) to drive a Schedule without the core package
+// depending on them.
+type OccurrenceSource interface {
+	// Next returns the first occurrence strictly after the given time.
+	// It returns false when the source has no further occurrences.
+	Next(after time.Time) (time.Time, bool)
+}
+
+// Occurrencer is an alias of OccurrenceSource. Any implementation, including the
+// built-in *Schedule, can be scheduled with Scheduler.ScheduleSource.
+type Occurrencer = OccurrenceSource
+
+// FixedTime is an OccurrenceSource firing once a day at a fixed time of day.
+// If Location is nil, the location of the time passed to Next is used.
+type FixedTime struct {
+	Hour, Minute, Second int
+	Location             *time.Location
+}
+
+// Next returns the first occurrence of the fixed time of day after the given time.
+func (f FixedTime) Next(after time.Time) (time.Time, bool) {
+	loc := f.Location
+	if loc == nil {
+		loc = after.Location()
+	}
+
+	t := after.In(loc)
+	next := time.Date(t.Year(), t.Month(), t.Day(), f.Hour, f.Minute, f.Second, 0, loc)
+	for !next.After(after) {
+		next = time.Date(next.Year(), next.Month(), next.Day()+1, f.Hour, f.Minute, f.Second, 0, loc)
+	}
+
+	return next, true
+}
+
+// Offset shifts every occurrence of src by d, e.g. Offset(sunset, -30*time.Minute).
+func Offset(src OccurrenceSource, d time.Duration) OccurrenceSource {
+	return offsetSource{src, d}
+}
+
+// offsetSource is an OccurrenceSource shifting another source by a fixed offset.
+type offsetSource struct {
+	src    OccurrenceSource
+	offset time.Duration
+}
+
+// Next returns the first shifted occurrence after the given time.
+func (o offsetSource) Next(after time.Time) (time.Time, bool) {
+	next, ok := o.src.Next(after.Add(-o.offset))
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return next.Add(o.offset), true
+}
+
+// exceptHorizon bounds how far Except searches for an occurrence that is not excluded.
+const exceptHorizon = 5 * 365 * 24 * time.Hour
+
+// Except returns a schedule firing on every occurrence of base that is not also an
+// occurrence of excluded, e.g. every 10 minutes except at the top of the hour.
+func Except(base, excluded *Schedule) *Schedule {
+	return &Schedule{Kind: KindSource, Source: exceptSource{base, excluded}}
+}
+
+// exceptSource is an OccurrenceSource skipping the occurrences of base matched by excluded.
+type exceptSource struct {
+	base, excluded *Schedule
+}
+
+// Next returns the first occurrence of base after the given time that excluded does not
+// match. It gives up when every occurrence within five years is excluded.
+func (e exceptSource) Next(after time.Time) (time.Time, bool) {
+	limit := after.Add(exceptHorizon)
+	for next, ok := e.base.Next(after); ok; next, ok = e.base.Next(next) {
+		if next.After(limit) {
+			break
+		}
+		if !e.excluded.Matches(next) {
+			return next, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test fixed time of day source
+func TestFixedTimeNext(t *testing.T) {
+	src := FixedTime{Hour: 18, Minute: 30, Location: time.UTC}
+
+	before := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	next, ok := src.Next(before)
+	if !ok || !next.Equal(time.Date(2024, 3, 10, 18, 30, 0, 0, time.UTC)) {
+		t.Fatalf("Expected same-day occurrence, got %v", next)
+	}
+
+	next, _ = src.Next(next)
+	if !next.Equal(time.Date(2024, 3, 11, 18, 30, 0, 0, time.UTC)) {
+		t.Fatalf("Expected next-day occurrence, got %v", next)
+	}
+}
+
+// Test offset source
+func TestOffsetSource(t *testing.T) {
+	src := Offset(FixedTime{Hour: 18, Location: time.UTC}, -30*time.Minute)
+
+	after := time.Date(2024, 3, 10, 17, 45, 0, 0, time.UTC)
+	next, ok := src.Next(after)
+	if !ok || !next.Equal(time.Date(2024, 3, 11, 17, 30, 0, 0, time.UTC)) {
+		t.Fatalf("Expected shifted occurrence on next day, got %v", next)
+	}
+}
+
+// stepSource fires every step until limit occurrences have been produced.
+type stepSource struct {
+	step  time.Duration
+	limit int32
+	count atomic.Int32
+}
+
+func (s *stepSource) Next(after time.Time) (time.Time, bool) {
+	if s.count.Add(1) > s.limit {
+		return time.Time{}, false
+	}
+	return after.Add(s.step), true
+}
+
+// Test scheduling with a custom source
+func TestScheduleSource(t *testing.T) {
+	s := New(time.Now())
+
+	var count atomic.Int32
+	src := &stepSource{step: 50 * time.Millisecond, limit: 3}
+	cancel, err := s.ScheduleSource(src, func(event Event) error {
+		count.Add(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cancel()
+
+	time.Sleep(300 * time.Millisecond)
+
+	// The schedule ends once the source is exhausted.
+	if c := count.Load(); c != 3 {
+		t.Fatalf("Expected handler to run 3 times, ran %d times", c)
+	}
+}
+
+// Test scheduling a nil source
+func TestScheduleSourceNil(t *testing.T) {
+	s := New(time.Now())
+	_, err := s.ScheduleSource(nil, func(event Event) error { return nil })
+	if err == nil {
+		t.Fatal("Expected error for nil source, got nil")
+	}
+}
+
+// nthBusinessDay is an Occurrencer firing at midnight on the nth business day of every month.
+type nthBusinessDay struct {
+	n int
+}
+
+func (b nthBusinessDay) Next(after time.Time) (time.Time, bool) {
+	month := time.Date(after.Year(), after.Month(), 1, 0, 0, 0, 0, after.Location())
+	for {
+		day, count := month, 0
+		for day.Month() == month.Month() {
+			if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+				count++
+				if count == b.n {
+					break
+				}
+			}
+			day = day.AddDate(0, 0, 1)
+		}
+		if count == b.n && day.After(after) {
+			return day, true
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+}
+
+// Test a custom Occurrencer and the built-in schedules implementing it
+func TestOccurrencer(t *testing.T) {
+	var o Occurrencer = nthBusinessDay{n: 3}
+
+	// The 3rd business day of March 2024 is Tuesday the 5th, of April Wednesday the 3rd.
+	after := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	next, _ := o.Next(after)
+	if want := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+	next, _ = o.Next(next)
+	if want := time.Date(2024, 4, 3, 0, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+
+	ce, err := Parse("@every 1h")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	o = ce
+	next, ok := o.Next(after.Add(90 * time.Minute))
+	if !ok || !next.Equal(after.Add(2*time.Hour)) {
+		t.Fatalf("Expected the schedule to align to the epoch, got %v", next)
+	}
+
+	s := New(time.Now())
+	cancel, err := s.ScheduleSource(nthBusinessDay{n: 1}, func(event Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cancel()
+}
+
+// Test excluding the occurrences of one schedule from another
+func TestExcept(t *testing.T) {
+	base, err := parse("@every 10m")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	excluded, err := parse("@hourly")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	start := time.Date(2024, 3, 10, 11, 30, 0, 0, time.UTC)
+	got := Except(base, excluded).Between(start, start.Add(time.Hour))
+	want := []time.Time{
+		time.Date(2024, 3, 10, 11, 30, 0, 0, time.UTC),
+		time.Date(2024, 3, 10, 11, 40, 0, 0, time.UTC),
+		time.Date(2024, 3, 10, 11, 50, 0, 0, time.UTC),
+		time.Date(2024, 3, 10, 12, 10, 0, 0, time.UTC),
+		time.Date(2024, 3, 10, 12, 20, 0, 0, time.UTC),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+
+	// The occurrence following an excluded one skips past it.
+	next := Except(base, excluded).NextOccurrence(time.Date(2024, 3, 10, 11, 50, 0, 0, time.UTC))
+	if want := time.Date(2024, 3, 10, 12, 10, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, next)
+	}
+}
+
+// Test a schedule whose occurrences are all excluded is exhausted
+func TestExceptEverything(t *testing.T) {
+	base, err := parse("@every 1h")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	excluded, err := parse("@every 30m")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if next, ok := Except(base, excluded).Next(time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)); ok {
+		t.Fatalf("Expected no occurrence, got %v", next)
+	}
+}
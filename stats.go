@@ -0,0 +1,125 @@
+package scheduler
+
+import "time"
+
+// lagWeight is the weight of the latest sample in the moving average of the lag.
+const lagWeight = 0.125
+
+// Stats are the run statistics of a task.
+type Stats struct {
+	// Runs is the number of handler invocations, including failed ones.
+	Runs int
+	// Errors is the number of handler invocations that returned an error.
+	Errors int
+	// AvgLag is the exponentially weighted moving average of how late scheduled
+	// runs started relative to their occurrence.
+	AvgLag time.Duration
+	// MaxLag is the largest lag of a scheduled run.
+	MaxLag time.Duration
+	// LastRun is when the handler last started, or the zero time if it never ran.
+	LastRun time.Time
+	// Dropped is the number of events a task scheduled with ScheduleChan dropped
+	// because its channel was full.
+	Dropped int
+}
+
+// Stats returns the run statistics of the task.
+func (h *Handle) Stats() Stats {
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+	return h.stats
+}
+
+// ResetStats atomically zeroes the run statistics of the task. Since WithMaxRuns counts
+// the runs in the statistics, resetting them re-arms the limit. The durations histogram
+// is kept.
+func (h *Handle) ResetStats() {
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+	h.stats = Stats{}
+}
+
+// RecentEvents returns the most recent events of the task, oldest first, along with
+// the error the handler returned for each. It is empty unless the task was scheduled
+// with WithHistory.
+func (h *Handle) RecentEvents() []Event {
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+
+	events := make([]Event, 0, len(h.history))
+	events = append(events, h.history[h.historyPos:]...)
+	return append(events, h.history[:h.historyPos]...)
+}
+
+// NextRun returns the next occurrence the task is waiting for. It is unchanged
+// once the task has stopped.
+func (h *Handle) NextRun() time.Time {
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+	return h.nextRun
+}
+
+// FirstRun returns the first occurrence of the task, as determined when it was
+// scheduled, or the zero time if the schedule has no occurrences. Tasks held by
+// WithStartBarrier determine it anew when the scheduler starts.
+func (h *Handle) FirstRun() time.Time {
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+	return h.firstRun
+}
+
+// publishNext makes the next occurrence available to NextRun.
+func (h *Handle) publishNext() {
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+	h.nextRun = h.next
+}
+
+// recordRun counts a handler invocation for event that started at began and returned
+// err, and keeps the event in the history.
+func (h *Handle) recordRun(event Event, began time.Time, err error) {
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+
+	h.stats.Runs++
+	h.stats.LastRun = began
+	if err != nil {
+		h.stats.Errors++
+	}
+
+	if h.historySize <= 0 {
+		return
+	}
+	event.Err = err
+	if len(h.history) < h.historySize {
+		h.history = append(h.history, event)
+		return
+	}
+	h.history[h.historyPos] = event
+	h.historyPos = (h.historyPos + 1) % h.historySize
+}
+
+// recordDrop counts an event dropped by a full channel.
+func (h *Handle) recordDrop() {
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+	h.stats.Dropped++
+}
+
+// recordLag records the lag of a scheduled run and reports it if it exceeds the threshold.
+func (h *Handle) recordLag(event Event, lag time.Duration) {
+	h.statsMu.Lock()
+	if h.stats.Runs == 0 {
+		h.stats.AvgLag = lag
+	} else {
+		h.stats.AvgLag += time.Duration(lagWeight * float64(lag-h.stats.AvgLag))
+	}
+	if lag > h.stats.MaxLag {
+		h.stats.MaxLag = lag
+	}
+	h.statsMu.Unlock()
+
+	if h.onLag != nil && lag > h.lagThreshold {
+		h.onLag(event, lag)
+	}
+}
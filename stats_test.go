@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// Test lag is recorded for late runs and reported past the threshold
+func TestStatsLag(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock))
+
+	events := make(chan Event)
+	var lags []time.Duration
+	h, err := s.ScheduleHandle("@every 10s", func(event Event) error {
+		events <- event
+		return nil
+	}, WithLagThreshold(2*time.Second, func(event Event, lag time.Duration) {
+		lags = append(lags, lag)
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	// On time, then 3s late.
+	clock.Advance(10 * time.Second)
+	<-events
+	clock.Advance(13 * time.Second)
+	event := <-events
+
+	if !event.Scheduled.Equal(start.Add(20 * time.Second)) {
+		t.Fatalf("Expected run scheduled at 20s, got %v", event.Scheduled)
+	}
+
+	stats := h.Stats()
+	if stats.MaxLag != 3*time.Second {
+		t.Fatalf("Expected max lag of 3s, got %v", stats.MaxLag)
+	}
+	if want := 3 * time.Second / 8; stats.AvgLag != want {
+		t.Fatalf("Expected average lag of %v, got %v", want, stats.AvgLag)
+	}
+	if len(lags) != 1 || lags[0] != 3*time.Second {
+		t.Fatalf("Expected one lag report of 3s, got %v", lags)
+	}
+}
+
+// Test resetting stats mid-run restarts the counters and re-arms the run limit
+func TestResetStats(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	errFail := errors.New("transient")
+	events := make(chan Event)
+	h, err := s.ScheduleHandle("@every 1s", func(event Event) error {
+		events <- event
+		if event.Scheduled.Equal(start.Add(2 * time.Second)) {
+			return errFail
+		}
+		return nil
+	}, WithStopUntilFirstSuccess(), WithMaxRuns(3))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	for i := 1; i <= 2; i++ {
+		clock.Advance(time.Second)
+		<-events
+	}
+	waitRuns(t, h, 2)
+	if stats := h.Stats(); stats.Runs != 2 || stats.Errors != 1 {
+		t.Fatalf("Expected 2 runs and 1 error, got %+v", stats)
+	}
+
+	h.ResetStats()
+	if stats := h.Stats(); stats != (Stats{}) {
+		t.Fatalf("Expected zeroed stats, got %+v", stats)
+	}
+
+	// The counters restart, and the run limit allows three more runs.
+	for i := 3; i <= 5; i++ {
+		clock.Advance(time.Second)
+		<-events
+	}
+	if reason, _ := h.Wait(); reason != Exhausted {
+		t.Fatalf("Expected reason %v, got %v", Exhausted, reason)
+	}
+	if stats := h.Stats(); stats.Runs != 3 || stats.Errors != 0 {
+		t.Fatalf("Expected 3 runs and no errors, got %+v", stats)
+	}
+}
+
+// Test the first occurrence is known as soon as the task is scheduled
+func TestFirstRun(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start.Add(90 * time.Second))
+	s := New(start, WithClock(clock))
+
+	h, err := s.ScheduleHandle("@every 1m", func(Event) error { return nil })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	first := h.FirstRun()
+	if !first.After(clock.Now()) {
+		t.Fatalf("Expected first run after %v, got %v", clock.Now(), first)
+	}
+	if !h.schedule.Matches(first) {
+		t.Fatalf("Expected first run %v to match the schedule", first)
+	}
+	if want := start.Add(2 * time.Minute); !first.Equal(want) {
+		t.Fatalf("Expected first run at %v, got %v", want, first)
+	}
+
+	// It is kept once the task has run.
+	clock.Advance(30 * time.Second)
+	waitRuns(t, h, 1)
+	if !h.FirstRun().Equal(first) {
+		t.Fatalf("Expected first run to stay %v, got %v", first, h.FirstRun())
+	}
+}
+
+// Test the history holds the last events and evicts older ones
+func TestWithHistory(t *testing.T) {
+	start := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	s := New(start, WithClock(clock), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	errFail := errors.New("transient")
+	h, err := s.ScheduleHandle("@every 1s", func(event Event) error {
+		if event.Scheduled.Equal(start.Add(4 * time.Second)) {
+			return errFail
+		}
+		return nil
+	}, WithStopUntilFirstSuccess(), WithHistory(3))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer h.Cancel()
+
+	if events := h.RecentEvents(); len(events) != 0 {
+		t.Fatalf("Expected no events before the first run, got %v", events)
+	}
+
+	for i := 1; i <= 5; i++ {
+		waitArmed(t, clock, start.Add(time.Duration(i)*time.Second))
+		clock.Advance(time.Second)
+	}
+	waitRuns(t, h, 5)
+
+	events := h.RecentEvents()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	for i, event := range events {
+		if want := start.Add(time.Duration(i+3) * time.Second); !event.Scheduled.Equal(want) {
+			t.Fatalf("Expected event %d scheduled at %v, got %v", i, want, event.Scheduled)
+		}
+	}
+	if events[0].Err != nil || !errors.Is(events[1].Err, errFail) || events[2].Err != nil {
+		t.Fatalf("Expected only the second event to have failed, got %v, %v and %v", events[0].Err, events[1].Err, events[2].Err)
+	}
+}